@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/hub"
 	"github.com/gedsonn/zaapi/internal/maneger"
 	server "github.com/gedsonn/zaapi/internal/server/http"
+	"github.com/gedsonn/zaapi/internal/webhook"
 	"github.com/spf13/cobra"
 )
 
@@ -32,22 +40,20 @@ var rootCmd = &cobra.Command{
 }
 
 func start(cmd *cobra.Command, args []string) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		log.Warn("Falha ao obter diretório atual, usando fallback em /etc/zaapi/config.yml")
-	}
-
-	configPath = fmt.Sprintf("%s/config.yml", cwd)
-	log.Infof("Usando arquivo de configuração: %s", configPath)
+	cfg := setup()
 
-	cfg, err := config.Load(configPath);
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
 	m, err := maneger.Load()
 	if err != nil {
 		panic(err)
 	}
+
+	// Webhooks recebem os eventos de todas as instâncias.
+	dispatcher := webhook.New(cfg, m)
+	maneger.OnEvent(dispatcher.Handle)
+
+	// WebSockets recebem os eventos em tempo real.
+	events := hub.New()
+	maneger.OnEvent(events.Handle)
 	fmt.Printf("%v", m)
 
 	err = m.Sync()
@@ -55,19 +61,114 @@ func start(cmd *cobra.Command, args []string) {
 		panic(err)
 	}
 
+	// SIGINT/SIGTERM iniciam o desligamento.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var srv *http.Server
 	if cfg.Server.Enable {
 		//inicializar o servidor http
 		log.Infof("Iniciando servidor HTTP na porta %d", cfg.Server.Port)
+		srv = &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+			Handler: server.Configure(m, events),
+		}
+		// Streams de WebSocket e SSE não terminam sozinhos.
+		srv.RegisterOnShutdown(events.Close)
+
 		go func() {
-			s := server.Configure(m)
-			if err := http.ListenAndServe(fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port), s); err != nil {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				log.Fatalf("Erro ao iniciar o servidor HTTP: %v", err)
 			}
 		}()
 	}
 
-	//impedir que o programa termine
-	select {}
+	//aguarda o sinal de desligamento
+	<-ctx.Done()
+	stop()
+
+	shutdown(cfg, srv, dispatcher, m)
+}
+
+// setup carrega o config.yml do diretório atual e abre o banco de dados,
+// recusando um banco com migrações pendentes. Se o banco não abrir, segue
+// sem ele.
+func setup() *config.Configuration {
+	cfg := loadConfig()
+
+	// O banco precisa estar aberto antes das instâncias e dos webhooks, que
+	// restauram a fila e o outbox a partir dele.
+	db := database.FromConfig(cfg.Database)
+	err := database.Initialize(db, cfg.Database.AutoMigrate)
+	switch {
+	case err == nil:
+		log.Infof("Banco de dados: %s", db.Driver)
+	case errors.Is(err, database.ErrUnavailable):
+		log.Errorf("Erro ao abrir o banco de dados (%s), seguindo sem ele: %v", db.Driver, err)
+	default:
+		log.Fatalf("Erro no banco de dados (%s): %v", db.Driver, err)
+	}
+
+	return cfg
+}
+
+// loadConfig carrega o config.yml do diretório atual.
+func loadConfig() *config.Configuration {
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Warn("Falha ao obter diretório atual, usando fallback em /etc/zaapi/config.yml")
+	}
+
+	configPath = fmt.Sprintf("%s/config.yml", cwd)
+	log.Infof("Usando arquivo de configuração: %s", configPath)
+
+	cfg, err := config.Load(configPath);
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	token, err := config.EnsureToken(configPath)
+	if err != nil {
+		log.Fatalf("Erro ao gerar o token global: %v", err)
+	}
+	if token != "" {
+		log.Warnf("Token global vazio ou de exemplo; gerado um novo e salvo em %s: %s", configPath, token)
+		cfg.Token = token
+	}
+
+	return cfg
+}
+
+// shutdown encerra o processo dentro de server.shutdown_timeout: para de aceitar
+// requisições e aguarda as em andamento, desconecta as instâncias sem marcá-las
+// como paradas e entrega os webhooks pendentes, inclusive os gerados pelo
+// próprio desligamento. O que sobrar no outbox é retomado na próxima execução.
+func shutdown(cfg *config.Configuration, srv *http.Server, dispatcher *webhook.Dispatcher, m *maneger.Manager) {
+	timeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	log.Infof("Desligando (prazo de %s)...", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Errorf("Erro ao desligar o servidor HTTP: %v", err)
+		}
+	}
+
+	if err := m.Shutdown(ctx); err != nil {
+		log.Errorf("Erro ao desconectar as instâncias: %v", err)
+	}
+
+	if err := dispatcher.Close(ctx); err != nil {
+		log.Errorf("Erro ao entregar os webhooks pendentes: %v", err)
+	}
+
+	log.Info("Zaapi encerrado")
 }
 
 var versionCommand = &cobra.Command{
@@ -87,6 +188,99 @@ var debugCommand = &cobra.Command{
 	},
 }
 
+var storeCommand = &cobra.Command{
+	Use:   "store",
+	Short: "Gerencia o store de aparelhos do WhatsApp",
+}
+
+var storeImportCommand = &cobra.Command{
+	Use:   "import",
+	Short: "Importa os sessions/<id>/store.db para o store no banco de dados",
+	Long: `Copia os aparelhos de cada sessions/<id>/store.db para o store compartilhado
+no banco configurado em database e associa cada aparelho à sua instância.
+Os arquivos originais não são alterados. Depois de importar, defina
+whatsapp.store: database no config.yml.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setup()
+
+		n, err := maneger.ImportStores(cmd.Context())
+		if err != nil {
+			log.Fatalf("Erro ao importar os stores: %v", err)
+		}
+
+		fmt.Printf("%d aparelho(s) importado(s). Defina whatsapp.store: database no config.yml para usá-los.\n", n)
+	},
+}
+
+var migrateSteps int
+
+var migrateCommand = &cobra.Command{
+	Use:   "migrate",
+	Short: "Gerencia as migrações do banco de dados",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		rootCmd.PersistentPreRun(cmd, args)
+
+		cfg := loadConfig()
+		db := database.FromConfig(cfg.Database)
+		if err := database.Open(db); err != nil {
+			log.Fatalf("Erro ao abrir o banco de dados (%s): %v", db.Driver, err)
+		}
+	},
+}
+
+var migrateUpCommand = &cobra.Command{
+	Use:   "up",
+	Short: "Aplica as migrações pendentes",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ran, err := database.MigrateUp()
+		for _, m := range ran {
+			fmt.Printf("aplicada  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Erro ao migrar: %v", err)
+		}
+		if len(ran) == 0 {
+			fmt.Println("Nenhuma migração pendente")
+		}
+	},
+}
+
+var migrateDownCommand = &cobra.Command{
+	Use:   "down",
+	Short: "Desfaz a última migração aplicada",
+	Run: func(cmd *cobra.Command, _ []string) {
+		undone, err := database.MigrateDown(migrateSteps)
+		for _, m := range undone {
+			fmt.Printf("desfeita  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Erro ao desfazer a migração: %v", err)
+		}
+		if len(undone) == 0 {
+			fmt.Println("Nenhuma migração aplicada")
+		}
+	},
+}
+
+var migrateStatusCommand = &cobra.Command{
+	Use:   "status",
+	Short: "Lista as migrações e se já foram aplicadas",
+	Run: func(cmd *cobra.Command, _ []string) {
+		list, err := database.Status()
+		if err != nil {
+			log.Fatalf("Erro ao ler as migrações: %v", err)
+		}
+
+		for _, m := range list {
+			applied := "pendente"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%-30s %s\n", m.Version, m.Name, applied)
+		}
+	},
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalf("Erro ao executar o Zaapi: %v", err)
@@ -97,6 +291,13 @@ func init() {
 	rootCmd.AddCommand(versionCommand)
 	rootCmd.AddCommand(debugCommand)
 
+	storeCommand.AddCommand(storeImportCommand)
+	rootCmd.AddCommand(storeCommand)
+
+	migrateDownCommand.Flags().IntVarP(&migrateSteps, "steps", "n", 1, "Quantidade de migrações a desfazer")
+	migrateCommand.AddCommand(migrateUpCommand, migrateDownCommand, migrateStatusCommand)
+	rootCmd.AddCommand(migrateCommand)
+
 	rootCmd.PersistentFlags().BoolVarP(
 		&debug,
 		"debug",
@@ -0,0 +1,200 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Escopos que podem ser concedidos a uma chave.
+const (
+	ScopeMessagesSend   = "messages:send"   // enviar mensagens
+	ScopeMessagesRead   = "messages:read"   // receber eventos por WebSocket e consultar o histórico
+	ScopeGroupsManage   = "groups:manage"   // gerenciar grupos
+	ScopeSessionsRead   = "sessions:read"   // consultar o estado das sessões
+	ScopeSessionsAdmin  = "sessions:admin"  // criar, parear, parar, deslogar e remover sessões
+	ScopeWebhooksManage = "webhooks:manage" // configurar e reenviar webhooks
+)
+
+// Scopes lista todos os escopos válidos.
+var Scopes = []string{
+	ScopeMessagesSend,
+	ScopeMessagesRead,
+	ScopeGroupsManage,
+	ScopeSessionsRead,
+	ScopeSessionsAdmin,
+	ScopeWebhooksManage,
+}
+
+// prefix identifica as chaves emitidas pelo zaapi.
+const prefix = "zk_"
+
+// touchInterval evita gravar o último uso a cada requisição.
+const touchInterval = time.Minute
+
+var (
+	ErrInvalidKey   = errors.New("chave de API inválida")
+	ErrExpiredKey   = errors.New("chave de API expirada")
+	ErrIPNotAllowed = errors.New("IP não autorizado para esta chave")
+)
+
+// Options são os dados de uma nova chave.
+type Options struct {
+	Name       string
+	InstanceID string
+	Scopes     []string
+	AllowedIPs []string
+	ExpiresAt  *time.Time
+}
+
+// Key é uma chave autenticada.
+type Key struct {
+	ID         string
+	InstanceID string
+	Scopes     []string
+}
+
+// Allows informa se a chave pode usar scope na instância (vazia para rotas globais).
+func (k *Key) Allows(scope, instance string) bool {
+	if k.InstanceID != "" && k.InstanceID != instance {
+		return false
+	}
+	return slices.Contains(k.Scopes, scope)
+}
+
+// Create gera e grava uma nova chave. A chave em texto só é retornada aqui.
+func Create(opts Options) (string, *models.APIKey, error) {
+	for _, scope := range opts.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", nil, fmt.Errorf("escopo desconhecido: %s", scope)
+		}
+	}
+	if len(opts.Scopes) == 0 {
+		return "", nil, errors.New("informe ao menos um escopo")
+	}
+
+	for _, ip := range opts.AllowedIPs {
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return "", nil, fmt.Errorf("IP ou CIDR inválido: %s", ip)
+			}
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	key := prefix + hex.EncodeToString(b)
+
+	row := &models.APIKey{
+		ID:         uuid.NewString(),
+		Name:       opts.Name,
+		Prefix:     key[:len(prefix)+8],
+		Hash:       hash(key),
+		InstanceID: opts.InstanceID,
+		Scopes:     strings.Join(opts.Scopes, ","),
+		AllowedIPs: strings.Join(opts.AllowedIPs, ","),
+		ExpiresAt:  opts.ExpiresAt,
+	}
+
+	if err := database.Instance().Create(row).Error; err != nil {
+		return "", nil, err
+	}
+
+	return key, row, nil
+}
+
+// List retorna as chaves cadastradas, das mais novas para as mais antigas.
+func List() ([]models.APIKey, error) {
+	var rows []models.APIKey
+	err := database.Instance().Order("created_at DESC").Find(&rows).Error
+	return rows, err
+}
+
+// Revoke apaga a chave. Retorna false se ela não existir.
+func Revoke(id string) (bool, error) {
+	res := database.Instance().Where("id = ?", id).Delete(&models.APIKey{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Authenticate valida a chave para uma requisição vinda de ip e registra o uso.
+func Authenticate(key, ip string) (*Key, error) {
+	if !strings.HasPrefix(key, prefix) {
+		return nil, ErrInvalidKey
+	}
+
+	var row models.APIKey
+	err := database.Instance().Where("hash = ?", hash(key)).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if row.ExpiresAt != nil && now.After(*row.ExpiresAt) {
+		return nil, ErrExpiredKey
+	}
+
+	if !allowed(row.AllowedIPs, ip) {
+		return nil, ErrIPNotAllowed
+	}
+
+	if row.LastUsedAt == nil || now.Sub(*row.LastUsedAt) > touchInterval || row.LastUsedIP != ip {
+		err := database.Instance().Model(&models.APIKey{}).Where("id = ?", row.ID).
+			Updates(map[string]any{"last_used_at": now, "last_used_ip": ip}).Error
+		if err != nil {
+			log.Errorf("Erro ao registrar uso da chave %s: %v", row.ID, err)
+		}
+	}
+
+	return &Key{
+		ID:         row.ID,
+		InstanceID: row.InstanceID,
+		Scopes:     strings.Split(row.Scopes, ","),
+	}, nil
+}
+
+// allowed informa se ip está na lista de IPs/CIDRs. Lista vazia libera todos.
+func allowed(list, ip string) bool {
+	if list == "" {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, entry := range strings.Split(list, ",") {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if other := net.ParseIP(entry); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"os"
+	"slices"
 	"sync"
 
 	"github.com/goccy/go-yaml"
@@ -15,14 +18,32 @@ type ServerConfig struct {
 	Debug   bool   `yaml:"debug"`
 	Maneger bool   `yaml:"maneger"`
 	Swagger bool   `yaml:"swagger"`
+
+	ShutdownTimeout int `yaml:"shutdown_timeout"` // prazo do desligamento após SIGINT/SIGTERM, em segundos
+
+	// IPs ou CIDRs dos proxies reversos cujos X-Forwarded-For e X-Real-IP são
+	// aceitos como IP do cliente. Vazio não confia em nenhum.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// Origens aceitas nos WebSockets, como padrões de host ("painel.exemplo.com",
+	// "*.exemplo.com" ou "*" para qualquer uma). Vazio aceita só a própria origem.
+	// Clientes sem o header Origin, como os que não são navegadores, sempre passam.
+	AllowedOrigins []string `yaml:"allowed_origins"`
 }
 
 type DatabaseConfig struct {
+	Driver string `yaml:"driver"` // sqlite (padrão) ou postgres
+	Path   string `yaml:"path"`   // arquivo do sqlite; ":memory:" para um banco em memória
+	DSN    string `yaml:"dsn"`    // se definido, usado no lugar dos demais campos
+
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+
+	AutoMigrate bool `yaml:"auto_migrate"` // aplica as migrações pendentes ao iniciar
 }
 
 type RedisConfig struct {
@@ -33,31 +54,71 @@ type RedisConfig struct {
 }
 
 type WebhookConfig struct {
-	Global  string `yaml:"global"`
-	Local   string `yaml:"local"`
-	Enabled bool   `yaml:"enabled"`
-	Timeout int    `yaml:"timeout"`
-	Retries int    `yaml:"retries"`
+	Global    string `yaml:"global"`
+	Local     string `yaml:"local"`
+	Enabled   bool   `yaml:"enabled"`
+	Timeout   int    `yaml:"timeout"`
+	Retries   int    `yaml:"retries"`
+	Retention int    `yaml:"retention"` // dias que os eventos entregues ficam no outbox; 0 usa 7
 }
 
 type WhatsConfig struct {
 	Version string `yaml:"version"`
+	Store   string `yaml:"store"` // session (padrão): sessions/<id>/store.db; database: um store no banco configurado
+}
+
+// ReconnectConfig controla a reconexão automática das instâncias pareadas.
+type ReconnectConfig struct {
+	MaxAttempts int `yaml:"max_attempts"` // falhas seguidas antes de desistir; 0 tenta para sempre
+	BaseDelay   int `yaml:"base_delay"`   // espera antes da primeira tentativa, em segundos
+	MaxDelay    int `yaml:"max_delay"`    // espera máxima entre tentativas, em segundos
+	KeepAlive   int `yaml:"keepalive"`    // segundos sem resposta ao keepalive antes de reconectar
+}
+
+// Limits são os envios permitidos em cada janela. Zero desliga o limite da janela.
+type Limits struct {
+	PerSecond int `yaml:"per_second" json:"per_second"`
+	PerMinute int `yaml:"per_minute" json:"per_minute"`
+	PerDay    int `yaml:"per_day" json:"per_day"`
+}
+
+// RateLimitConfig define os limites de envio de cada instância e de cada chave de API.
+type RateLimitConfig struct {
+	Instance Limits `yaml:"instance"` // pode ser sobrescrito por instância
+	Key      Limits `yaml:"key"`
+}
+
+// QueueConfig define as pausas da fila de envio para simular um humano.
+type QueueConfig struct {
+	MinDelay    int  `yaml:"min_delay"`    // pausa mínima entre mensagens, em milissegundos
+	MaxDelay    int  `yaml:"max_delay"`    // pausa máxima entre mensagens, em milissegundos
+	Typing      bool `yaml:"typing"`       // envia "digitando..." antes de cada mensagem
+	TypingSpeed int  `yaml:"typing_speed"` // caracteres por segundo usados para calcular o tempo digitando
+	MaxTyping   int  `yaml:"max_typing"`   // tempo máximo digitando, em milissegundos
+	MaxPending  int  `yaml:"max_pending"`  // mensagens aguardando envio por sessão; acima disso o envio responde 429
+	Retention   int  `yaml:"retention"`    // dias que as mensagens finalizadas ficam no banco; 0 usa 7
 }
 
 type MediaConfig struct {
-	Path string `yaml:"path"`
+	Path     string `yaml:"path"`
+	MaxSize  int    `yaml:"max_size"`  // tamanho máximo das mídias enviadas, em MB
+	Download bool   `yaml:"download"`  // baixa as mídias recebidas para Path
+	SaveSent bool   `yaml:"save_sent"` // guarda uma cópia das mídias enviadas em Path
 }
 
 type Configuration struct {
-	Name     string         `yaml:"name"`
-	Token    string         `yaml:"token"`
-	Secret   string         `yaml:"secret"`
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Webhook  WebhookConfig  `yaml:"webhook"`
-	Whatsapp WhatsConfig    `yaml:"whatsapp"`
-	Media    MediaConfig    `yaml:"media"`
+	Name      string          `yaml:"name"`
+	Token     string          `yaml:"token"`
+	Secret    string          `yaml:"secret"`
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Webhook   WebhookConfig   `yaml:"webhook"`
+	Whatsapp  WhatsConfig     `yaml:"whatsapp"`
+	Reconnect ReconnectConfig `yaml:"reconnect"`
+	RateLimit RateLimitConfig `yaml:"ratelimit"`
+	Queue     QueueConfig     `yaml:"queue"`
+	Media     MediaConfig     `yaml:"media"`
 }
 
 var (
@@ -101,7 +162,7 @@ func Load(path string) (*Configuration, error) {
 func DefaultConfig() *Configuration {
 	return &Configuration{
 		Name:   "Zaapi",
-		Token:  "token",
+		Token:  "", // gerado na primeira execução, veja EnsureToken
 		Secret: "1234",
 
 		Server: ServerConfig{
@@ -111,14 +172,19 @@ func DefaultConfig() *Configuration {
 			Debug:   false,
 			Maneger: false,
 			Swagger: false,
+
+			ShutdownTimeout: 30,
 		},
 
 		Database: DatabaseConfig{
+			Driver:   "sqlite",
+			Path:     "zaapi.db",
 			Host:     "localhost",
 			Port:     5432,
 			User:     "zaapi",
 			Password: "zaapi",
 			Name:     "zaapi",
+			SSLMode:  "disable",
 		},
 
 		Redis: RedisConfig{
@@ -129,19 +195,45 @@ func DefaultConfig() *Configuration {
 		},
 
 		Webhook: WebhookConfig{
-			Global:  "http://localhost:8080",
-			Local:   "http://localhost:8080",
-			Enabled: false,
-			Timeout: 5,
-			Retries: 3,
+			Global:    "http://localhost:8080",
+			Local:     "http://localhost:8080",
+			Enabled:   false,
+			Timeout:   5,
+			Retries:   3,
+			Retention: 7,
 		},
 
 		Whatsapp: WhatsConfig{
 			Version: "latest",
+			Store:   "session",
+		},
+
+		Reconnect: ReconnectConfig{
+			MaxAttempts: 10,
+			BaseDelay:   2,
+			MaxDelay:    300,
+			KeepAlive:   180,
+		},
+
+		RateLimit: RateLimitConfig{
+			Instance: Limits{PerSecond: 1, PerMinute: 30, PerDay: 1000},
+			Key:      Limits{PerSecond: 5, PerMinute: 120},
+		},
+
+		Queue: QueueConfig{
+			MinDelay:    1000,
+			MaxDelay:    4000,
+			Typing:      true,
+			TypingSpeed: 15,
+			MaxTyping:   8000,
+			MaxPending:  1000,
+			Retention:   7,
 		},
 
 		Media: MediaConfig{
-			Path: "assets",
+			Path:     "assets",
+			MaxSize:  64,
+			Download: false,
 		},
 	}
 }
@@ -151,11 +243,33 @@ func Set(c *Configuration) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	if c.Token == "" {
-		c.Token = "zaapi-default-token"
+	_config = c
+}
+
+// defaultTokens são os tokens de exemplo de versões anteriores. Por serem
+// públicos, não protegem a API.
+var defaultTokens = []string{"", "token", "zaapi-default-token"}
+
+// EnsureToken troca um token global vazio ou de exemplo por um aleatório e
+// salva a configuração em path. Retorna o token gerado, ou "" se o
+// configurado já servia.
+func EnsureToken(path string) (string, error) {
+	mu.Lock()
+	if _config == nil || !slices.Contains(defaultTokens, _config.Token) {
+		mu.Unlock()
+		return "", nil
 	}
 
-	_config = c
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		mu.Unlock()
+		return "", err
+	}
+	_config.Token = hex.EncodeToString(b)
+	token := _config.Token
+	mu.Unlock()
+
+	return token, Save(path)
 }
 
 // Get returns a copy of the current configuration.
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureToken(t *testing.T) {
+	prev := Get()
+	t.Cleanup(func() { Set(prev) })
+
+	for _, token := range []string{"", "token", "zaapi-default-token"} {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		if err := os.WriteFile(path, []byte("token: \""+token+"\"\n"), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if _, err := Load(path); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+
+		generated, err := EnsureToken(path)
+		if err != nil {
+			t.Fatalf("EnsureToken(%q): %v", token, err)
+		}
+		if len(generated) != 64 || Get().Token != generated {
+			t.Fatalf("EnsureToken(%q) = %q, token atual %q", token, generated, Get().Token)
+		}
+
+		// O token gerado fica salvo e não muda na próxima execução.
+		if _, err := Load(path); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if Get().Token != generated {
+			t.Fatalf("token salvo = %q, esperado %q", Get().Token, generated)
+		}
+		if again, _ := EnsureToken(path); again != "" {
+			t.Fatalf("EnsureToken gerou outro token para %q", generated)
+		}
+	}
+}
+
+func TestEnsureTokenKeepsCustomToken(t *testing.T) {
+	prev := Get()
+	t.Cleanup(func() { Set(prev) })
+
+	cfg := DefaultConfig()
+	cfg.Token = "meu-token-secreto"
+	Set(cfg)
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if generated, err := EnsureToken(path); err != nil || generated != "" {
+		t.Fatalf("EnsureToken = %q, %v, esperado manter o token", generated, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("EnsureToken reescreveu a configuração sem gerar token")
+	}
+}
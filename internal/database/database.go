@@ -1,10 +1,13 @@
 package database
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
-	"github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/config"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -13,14 +16,84 @@ type Config struct {
 	DSN    string
 }
 
+// FromConfig monta a configuração de conexão a partir do config.yml. Sem
+// driver definido, usa sqlite no arquivo zaapi.db.
+func FromConfig(c config.DatabaseConfig) Config {
+	driver := c.Driver
+	if driver == "" {
+		driver = "sqlite"
+	}
+
+	if c.DSN != "" {
+		return Config{Driver: driver, DSN: c.DSN}
+	}
+
+	switch driver {
+	case "sqlite":
+		path := c.Path
+		if path == "" {
+			path = "zaapi.db"
+		}
+		return Config{Driver: driver, DSN: path}
+	case "postgres":
+		sslmode := c.SSLMode
+		if sslmode == "" {
+			sslmode = "disable"
+		}
+		return Config{
+			Driver: driver,
+			DSN: fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+				c.Host, c.Port, c.User, c.Password, c.Name, sslmode),
+		}
+	}
+
+	return Config{Driver: driver}
+}
+
 var DB *gorm.DB
 
-func Initialize(cfg Config) error {
-	var err error
+// ErrUnavailable indica que não foi possível abrir o banco. Sem ele, o Zaapi
+// segue com o session.yml e os recursos que exigem banco respondem 503.
+var ErrUnavailable = errors.New("banco de dados indisponível")
+
+// Initialize abre o banco e confere se o esquema está migrado. Com
+// autoMigrate, ou num banco novo sem nenhuma migração aplicada, aplica as
+// migrações pendentes em vez de falhar.
+func Initialize(cfg Config, autoMigrate bool) error {
+	if err := Open(cfg); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	if !autoMigrate {
+		done, err := applied(Instance())
+		if err != nil {
+			return err
+		}
+		autoMigrate = len(done) == 0
+	}
+
+	if autoMigrate {
+		if _, err := MigrateUp(); err != nil {
+			return err
+		}
+	}
+
+	return CheckMigrations()
+}
+
+// Open abre a conexão com o banco sem conferir o esquema. Usado pelos
+// comandos de migração.
+func Open(cfg Config) error {
+	var (
+		db  *gorm.DB
+		err error
+	)
 
 	switch cfg.Driver {
 	case "postgres":
-		DB, err = gorm.Open(postgres.Open(cfg.DSN), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(cfg.DSN), &gorm.Config{})
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(SQLiteDSN(cfg.DSN)), &gorm.Config{})
 	default:
 		return fmt.Errorf("driver não suportado: %s", cfg.Driver)
 	}
@@ -29,24 +102,56 @@ func Initialize(cfg Config) error {
 		return err
 	}
 
-	sqlDB, err := DB.DB()
+	sqlDB, err := db.DB()
 	if err != nil {
 		return err
 	}
 
-	sqlDB.SetMaxOpenConns(10)
-	sqlDB.SetMaxIdleConns(5)
-
-	err = DB.AutoMigrate(
-		&models.Instance{},
-	)
-	if err != nil {
-		return err
+	if cfg.Driver == "sqlite" {
+		// O sqlite aceita um escritor por vez, e cada conexão com ":memory:"
+		// abriria um banco diferente: uma única conexão, mantida aberta.
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+	} else {
+		sqlDB.SetMaxOpenConns(10)
+		sqlDB.SetMaxIdleConns(5)
 	}
 
+	DB, dbDriver = db, cfg.Driver
 	return nil
 }
 
+// SQLiteDSN liga as chaves estrangeiras e, em arquivos, o journal WAL e a
+// espera por locks, preservando os parâmetros já informados.
+func SQLiteDSN(dsn string) string {
+	params := []string{"_foreign_keys=on"}
+	if !strings.Contains(dsn, ":memory:") && !strings.Contains(dsn, "mode=memory") {
+		params = append(params, "_journal_mode=WAL", "_busy_timeout=5000")
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&")
+}
+
+// Close fecha a conexão aberta por Open.
+func Close() {
+	if DB == nil {
+		return
+	}
+	if sqlDB, err := DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+	DB = nil
+}
+
+// Available informa se o banco de dados foi inicializado.
+func Available() bool {
+	return DB != nil
+}
+
 func Instance() *gorm.DB {
 	if DB == nil {
 		panic("database: database not initialized")
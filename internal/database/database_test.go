@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database/models"
+)
+
+func TestFromConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.DatabaseConfig
+		want Config
+	}{
+		{"padrão", config.DatabaseConfig{}, Config{Driver: "sqlite", DSN: "zaapi.db"}},
+		{"memória", config.DatabaseConfig{Path: ":memory:"}, Config{Driver: "sqlite", DSN: ":memory:"}},
+		{"dsn", config.DatabaseConfig{Driver: "postgres", DSN: "postgres://x", Host: "ignorado"}, Config{Driver: "postgres", DSN: "postgres://x"}},
+		{"postgres", config.DatabaseConfig{Driver: "postgres", Host: "db", Port: 5432, User: "u", Password: "p", Name: "zaapi"},
+			Config{Driver: "postgres", DSN: "host=db port=5432 user=u password=p dbname=zaapi sslmode=disable"}},
+	}
+
+	for _, tt := range tests {
+		if got := FromConfig(tt.cfg); got != tt.want {
+			t.Errorf("%s: FromConfig = %+v, esperado %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		dsn, want string
+	}{
+		{":memory:", ":memory:?_foreign_keys=on"},
+		{"zaapi.db", "zaapi.db?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"},
+		{"file:zaapi.db?cache=shared", "file:zaapi.db?cache=shared&_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"},
+	}
+
+	for _, tt := range tests {
+		if got := SQLiteDSN(tt.dsn); got != tt.want {
+			t.Errorf("SQLiteDSN(%q) = %q, esperado %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+// TestInitializeInMemory garante que um banco em memória sobrevive entre
+// consultas: cada conexão nova com ":memory:" abriria um banco vazio.
+func TestInitializeInMemory(t *testing.T) {
+	if err := Initialize(Config{Driver: "sqlite", DSN: ":memory:"}, true); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(Close)
+
+	if !Available() {
+		t.Fatal("Available = false após Initialize")
+	}
+
+	if err := Instance().Create(&models.Instance{ID: "1", Name: "teste"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var got models.Instance
+	if err := Instance().First(&got, "id = ?", "1").Error; err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	if got.Name != "teste" {
+		t.Fatalf("Name = %q, esperado %q", got.Name, "teste")
+	}
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	if err := Open(Config{Driver: "mysql"}); err == nil {
+		Close()
+		t.Fatal("Open aceitou um driver não suportado")
+	}
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles guarda as migrações de cada driver, em
+// migrations/<driver>/<versão>_<nome>.up.sql e .down.sql.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrNotMigrated indica que o banco tem migrações pendentes.
+var ErrNotMigrated = errors.New("o banco de dados não está migrado")
+
+// Migration é uma versão do esquema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus é uma migração e quando ela foi aplicada; AppliedAt é nil
+// nas pendentes.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// SchemaMigration é a linha de schema_migrations gravada para cada migração
+// aplicada.
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// dbDriver é o driver aberto pelo Open, usado para escolher as migrações.
+var dbDriver string
+
+// Migrations lê as migrações do driver, em ordem de versão.
+func Migrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("sem migrações para o driver %s", driver)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migração com nome inválido: %s", e.Name())
+		}
+
+		prefix, label, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migração com versão inválida: %s", e.Name())
+		}
+
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migração %04d_%s sem o arquivo up ou down", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	slices.SortFunc(list, func(a, b Migration) int { return a.Version - b.Version })
+
+	return list, nil
+}
+
+// applied retorna as migrações já aplicadas, indexadas pela versão, criando
+// schema_migrations se ainda não existir.
+func applied(db *gorm.DB) (map[int]SchemaMigration, error) {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text,
+		applied_at timestamp
+	)`).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+// Status lista as migrações do driver e quando cada uma foi aplicada.
+func Status() ([]MigrationStatus, error) {
+	list, err := Migrations(dbDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := applied(Instance())
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(list))
+	for n, m := range list {
+		status[n] = MigrationStatus{Migration: m}
+		if row, ok := done[m.Version]; ok {
+			status[n].AppliedAt = &row.AppliedAt
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp aplica as migrações pendentes, cada uma em uma transação, e
+// retorna as que foram aplicadas.
+func MigrateUp() ([]Migration, error) {
+	list, err := Migrations(dbDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	db := Instance()
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range list {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migração %04d_%s: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+
+	return ran, nil
+}
+
+// MigrateDown desfaz as últimas steps migrações aplicadas, da mais nova para a
+// mais antiga, e retorna as que foram desfeitas.
+func MigrateDown(steps int) ([]Migration, error) {
+	list, err := Migrations(dbDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	db := Instance()
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var undone []Migration
+	for n := len(list) - 1; n >= 0 && len(undone) < steps; n-- {
+		m := list[n]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return undone, fmt.Errorf("migração %04d_%s: %w", m.Version, m.Name, err)
+		}
+		undone = append(undone, m)
+	}
+
+	return undone, nil
+}
+
+// CheckMigrations falha com ErrNotMigrated se alguma migração do driver
+// ainda não foi aplicada, e também se o banco tem migrações que este
+// binário não conhece (foi migrado por uma versão mais nova).
+func CheckMigrations() error {
+	list, err := Migrations(dbDriver)
+	if err != nil {
+		return err
+	}
+
+	done, err := applied(Instance())
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, m := range list {
+		if _, ok := done[m.Version]; !ok {
+			pending++
+		}
+		delete(done, m.Version)
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%w: %d migração(ões) pendente(s); rode zaapi migrate up", ErrNotMigrated, pending)
+	}
+	if len(done) > 0 {
+		return fmt.Errorf("o banco de dados tem %d migração(ões) desconhecida(s); atualize o Zaapi", len(done))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func tableExists(t *testing.T, name string) bool {
+	t.Helper()
+
+	var n int64
+	err := Instance().Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n).Error
+	if err != nil {
+		t.Fatalf("sqlite_master: %v", err)
+	}
+	return n > 0
+}
+
+func TestMigrationsMatchAcrossDrivers(t *testing.T) {
+	sqlite, err := Migrations("sqlite")
+	if err != nil {
+		t.Fatalf("Migrations(sqlite): %v", err)
+	}
+	postgres, err := Migrations("postgres")
+	if err != nil {
+		t.Fatalf("Migrations(postgres): %v", err)
+	}
+
+	if len(sqlite) == 0 || len(sqlite) != len(postgres) {
+		t.Fatalf("%d migrações no sqlite e %d no postgres", len(sqlite), len(postgres))
+	}
+	for n := range sqlite {
+		if sqlite[n].Version != n+1 {
+			t.Errorf("migração %d com versão %d: as versões devem ser sequenciais", n, sqlite[n].Version)
+		}
+		if sqlite[n].Version != postgres[n].Version || sqlite[n].Name != postgres[n].Name {
+			t.Errorf("migração %04d_%s do sqlite sem par no postgres (%04d_%s)",
+				sqlite[n].Version, sqlite[n].Name, postgres[n].Version, postgres[n].Name)
+		}
+	}
+
+	if _, err := Migrations("mysql"); err == nil {
+		t.Fatal("Migrations aceitou um driver sem migrações")
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	if err := Open(Config{Driver: "sqlite", DSN: ":memory:"}); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(Close)
+
+	list, _ := Migrations("sqlite")
+
+	if err := CheckMigrations(); !errors.Is(err, ErrNotMigrated) {
+		t.Fatalf("CheckMigrations num banco vazio = %v, esperado %v", err, ErrNotMigrated)
+	}
+
+	ran, err := MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(ran) != len(list) {
+		t.Fatalf("MigrateUp aplicou %d migrações, esperado %d", len(ran), len(list))
+	}
+	if err := CheckMigrations(); err != nil {
+		t.Fatalf("CheckMigrations após MigrateUp: %v", err)
+	}
+	if ran, _ := MigrateUp(); len(ran) != 0 {
+		t.Fatalf("MigrateUp repetido aplicou %d migrações", len(ran))
+	}
+
+	undone, err := MigrateDown(1)
+	if err != nil || len(undone) != 1 || undone[0].Version != list[len(list)-1].Version {
+		t.Fatalf("MigrateDown(1) = %+v, %v", undone, err)
+	}
+	if err := CheckMigrations(); !errors.Is(err, ErrNotMigrated) {
+		t.Fatalf("CheckMigrations após MigrateDown = %v, esperado %v", err, ErrNotMigrated)
+	}
+
+	status, err := Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for n, m := range status {
+		if pending := m.AppliedAt == nil; pending != (n == len(status)-1) {
+			t.Errorf("migração %04d_%s: pendente = %v", m.Version, m.Name, pending)
+		}
+	}
+
+	if _, err := MigrateDown(len(list)); err != nil {
+		t.Fatalf("MigrateDown(%d): %v", len(list), err)
+	}
+	if tableExists(t, "instances") {
+		t.Fatal("a tabela instances continua após desfazer todas as migrações")
+	}
+
+	if _, err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp após desfazer tudo: %v", err)
+	}
+	if !tableExists(t, "instances") || !tableExists(t, "messages") {
+		t.Fatal("tabelas ausentes após reaplicar as migrações")
+	}
+}
+
+func TestInitializeMigratesEmptySchema(t *testing.T) {
+	if err := Initialize(Config{Driver: "sqlite", DSN: ":memory:"}, false); err != nil {
+		t.Fatalf("Initialize num banco novo: %v", err)
+	}
+	t.Cleanup(Close)
+
+	if err := CheckMigrations(); err != nil {
+		t.Fatalf("CheckMigrations: %v", err)
+	}
+}
+
+func TestInitializeRefusesPendingMigrations(t *testing.T) {
+	cfg := Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "zaapi.db")}
+
+	if err := Open(cfg); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := MigrateDown(1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	Close()
+
+	err := Initialize(cfg, false)
+	t.Cleanup(Close)
+	if !errors.Is(err, ErrNotMigrated) {
+		t.Fatalf("Initialize com migração pendente = %v, esperado %v", err, ErrNotMigrated)
+	}
+
+	Close()
+	if err := Initialize(cfg, true); err != nil {
+		t.Fatalf("Initialize com auto_migrate: %v", err)
+	}
+}
+
+func TestCheckMigrationsRejectsUnknownVersion(t *testing.T) {
+	if err := Initialize(Config{Driver: "sqlite", DSN: ":memory:"}, true); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(Close)
+
+	if err := Instance().Create(&SchemaMigration{Version: 9999, Name: "futura"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := CheckMigrations(); err == nil || errors.Is(err, ErrNotMigrated) {
+		t.Fatalf("CheckMigrations com uma migração desconhecida = %v", err)
+	}
+}
+
+func TestInitializeUnavailable(t *testing.T) {
+	err := Initialize(Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "nao-existe", "zaapi.db")}, false)
+	if !errors.Is(err, ErrUnavailable) {
+		Close()
+		t.Fatalf("Initialize = %v, esperado %v", err, ErrUnavailable)
+	}
+	if Available() {
+		t.Fatal("Available = true sem banco")
+	}
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "message_status_changes";
+DROP TABLE IF EXISTS "campaign_recipients";
+DROP TABLE IF EXISTS "campaigns";
+DROP TABLE IF EXISTS "queued_messages";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "webhook_outboxes";
+DROP TABLE IF EXISTS "instances";
@@ -0,0 +1,14 @@
+CREATE TABLE "messages" (
+    "id" bigserial PRIMARY KEY,
+    "instance_id" text,
+    "message_id" text,
+    "chat" text,
+    "sender" text,
+    "from_me" boolean,
+    "type" text,
+    "text" text,
+    "payload" text,
+    "timestamp" timestamptz
+);
+CREATE UNIQUE INDEX "idx_messages_instance_message" ON "messages"("instance_id", "message_id");
+CREATE INDEX "idx_messages_chat_timestamp" ON "messages"("instance_id", "chat", "timestamp");
@@ -0,0 +1,118 @@
+-- Esquema criado até então pelo AutoMigrate. Usa IF NOT EXISTS para adotar
+-- bancos que já foram criados por ele.
+CREATE TABLE IF NOT EXISTS `instances` (
+    `id` text,
+    `name` text,
+    `token` text,
+    `number` text,
+    `device_jid` text,
+    `status` text,
+    `listen` numeric,
+    `stopped` numeric,
+    `reject_call` numeric,
+    `read_messages` numeric,
+    `rate_limit` text,
+    `webhook` text,
+    `webhook_events` integer,
+    `webhook_secret` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `webhook_outboxes` (
+    `id` text,
+    `event_id` text,
+    `instance_id` text,
+    `url` text,
+    `global` numeric,
+    `type` text,
+    `payload` text,
+    `status` text,
+    `attempts` integer,
+    `last_error` text,
+    `next_attempt` datetime,
+    `delivered_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_webhook_outboxes_event_id` ON `webhook_outboxes`(`event_id`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_outboxes_instance_id` ON `webhook_outboxes`(`instance_id`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_outboxes_status` ON `webhook_outboxes`(`status`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_outboxes_next_attempt` ON `webhook_outboxes`(`next_attempt`);
+
+CREATE TABLE IF NOT EXISTS `api_keys` (
+    `id` text,
+    `name` text,
+    `prefix` text,
+    `hash` text,
+    `instance_id` text,
+    `scopes` text,
+    `allowed_ips` text,
+    `expires_at` datetime,
+    `last_used_at` datetime,
+    `last_used_ip` text,
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_api_keys_hash` ON `api_keys`(`hash`);
+CREATE INDEX IF NOT EXISTS `idx_api_keys_instance_id` ON `api_keys`(`instance_id`);
+
+CREATE TABLE IF NOT EXISTS `queued_messages` (
+    `id` text,
+    `instance_id` text,
+    `to` text,
+    `priority` integer,
+    `typing` numeric,
+    `payload` blob,
+    `status` text,
+    `error` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `sent_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_queued_messages_instance_id` ON `queued_messages`(`instance_id`);
+CREATE INDEX IF NOT EXISTS `idx_queued_messages_status` ON `queued_messages`(`status`);
+
+CREATE TABLE IF NOT EXISTS `campaigns` (
+    `id` text,
+    `instance_id` text,
+    `name` text,
+    `template` text,
+    `priority` integer,
+    `typing` numeric,
+    `status` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `finished_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_campaigns_instance_id` ON `campaigns`(`instance_id`);
+CREATE INDEX IF NOT EXISTS `idx_campaigns_status` ON `campaigns`(`status`);
+
+CREATE TABLE IF NOT EXISTS `campaign_recipients` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `campaign_id` text,
+    `phone` text,
+    `vars` text,
+    `status` text,
+    `message_id` text,
+    `error` text,
+    `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_campaign_recipient_status` ON `campaign_recipients`(`campaign_id`, `status`);
+CREATE INDEX IF NOT EXISTS `idx_campaign_recipients_message_id` ON `campaign_recipients`(`message_id`);
+
+CREATE TABLE IF NOT EXISTS `message_status_changes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `instance_id` text,
+    `message_id` text,
+    `chat` text,
+    `participant` text,
+    `status` text,
+    `timestamp` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_message_status_changes_instance_id` ON `message_status_changes`(`instance_id`);
+CREATE INDEX IF NOT EXISTS `idx_message_status_changes_message_id` ON `message_status_changes`(`message_id`);
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// APIKey é uma chave de acesso com escopos. Só o hash SHA-256 da chave é
+// guardado; Prefix identifica a chave nas listagens.
+type APIKey struct {
+	ID         string `gorm:"primaryKey"`
+	Name       string
+	Prefix     string
+	Hash       string `gorm:"uniqueIndex"`
+	InstanceID string `gorm:"index"` // vazio vale para todas as instâncias
+	Scopes     string // separados por vírgula
+	AllowedIPs string // IPs ou CIDRs separados por vírgula; vazio libera todos
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	CreatedAt  time.Time
+}
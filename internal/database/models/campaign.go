@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Situação de uma campanha.
+const (
+	CampaignRunning   = "running"
+	CampaignPaused    = "paused"
+	CampaignCanceled  = "canceled"
+	CampaignCompleted = "completed"
+)
+
+// Situação de um destinatário da campanha. A ordem de pending a read é a
+// ordem em que a mensagem avança; failed e canceled são finais.
+const (
+	RecipientPending   = "pending"
+	RecipientQueued    = "queued"
+	RecipientSent      = "sent"
+	RecipientDelivered = "delivered"
+	RecipientRead      = "read"
+	RecipientFailed    = "failed"
+	RecipientCanceled  = "canceled"
+)
+
+// Campaign é um envio em massa do mesmo modelo de texto para uma lista de
+// destinatários.
+type Campaign struct {
+	ID         string `gorm:"primaryKey"`
+	InstanceID string `gorm:"index"`
+	Name       string
+	Template   string // texto com {{variavel}}
+	Priority   int
+	Typing     *bool
+	Status     string `gorm:"index"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// CampaignRecipient é um destinatário da campanha com as variáveis do modelo.
+type CampaignRecipient struct {
+	ID         uint   `gorm:"primaryKey"`
+	CampaignID string `gorm:"index:idx_campaign_recipient_status"`
+	Phone      string
+	Vars       string `gorm:"type:text"` // JSON com as variáveis
+	Status     string `gorm:"index:idx_campaign_recipient_status"`
+	MessageID  string `gorm:"index"`
+	Error      string
+	UpdatedAt  time.Time
+}
@@ -4,24 +4,35 @@ import (
 	"database/sql"
 	"time"
 )
+
 type WebhookEvent int
 
 type Instance struct {
 	//Instacia
-    ID        string   `gorm:"primaryKey;autoIncrement:false"`
-	Name 	  string
-	Token     string
-	Number    sql.NullString `gorm:"type:text"`
-	Status    int
+	ID     string `gorm:"primaryKey;autoIncrement:false"`
+	Name   string
+	Token  string
+	Number sql.NullString `gorm:"type:text"`         // preenchido após o pareamento
+	Device string         `gorm:"column:device_jid"` // JID do aparelho no store do whatsmeow
+	Status string         // models.State
+
+	//Flags
+	Listen  bool
+	Stopped bool
+
 	//Configurações
-	RejectCall bool
+	RejectCall   bool
 	ReadMessages bool
+	RateLimit    string `gorm:"type:text"` // config.Limits em JSON; vazio usa os limites globais
 
 	//webhook
-	Webhook   string
+	Webhook        string
 	Webhook_events int
+	WebhookSecret  string
+
 	//Outros
 	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 
@@ -34,4 +45,22 @@ const (
 	EventLoggedIn                                 // 16
 	EventLoggedOut                                // 32
 	PairSuccess									  // 64 
-)
\ No newline at end of file
+	EventReceipt                                  // 128
+	EventPresence                                 // 256
+	EventConnection                               // 512
+	EventQueue                                    // 1024
+	EventSchedule                                 // 2048
+	EventMessageStatus                            // 4096
+)
+
+// AllEvents habilita todos os eventos.
+const AllEvents WebhookEvent = MessageReceived | MessageSender | EventNewContact | EventQR | EventLoggedIn | EventLoggedOut | PairSuccess |
+	EventReceipt | EventPresence | EventConnection | EventQueue | EventSchedule | EventMessageStatus
+
+// Has informa se o evento está habilitado na máscara. Uma máscara zerada habilita todos.
+func (w WebhookEvent) Has(e WebhookEvent) bool {
+	if w == 0 {
+		return true
+	}
+	return w&e != 0
+}
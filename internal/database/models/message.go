@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Message é uma mensagem recebida ou enviada pela instância, guardada para o
+// histórico das conversas. Payload é a mensagem normalizada em JSON, como é
+// entregue aos webhooks.
+type Message struct {
+	ID         uint   `gorm:"primaryKey"`
+	InstanceID string `gorm:"uniqueIndex:idx_messages_instance_message;index:idx_messages_chat_timestamp"`
+	MessageID  string `gorm:"uniqueIndex:idx_messages_instance_message"`
+	Chat       string `gorm:"index:idx_messages_chat_timestamp"`
+	Sender     string
+	FromMe     bool
+	Type       string
+	Text       string    `gorm:"type:text"`
+	Payload    string    `gorm:"type:text"`
+	Timestamp  time.Time `gorm:"index:idx_messages_chat_timestamp"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Situação de uma mensagem na fila de envio.
+const (
+	QueueQueued   = "queued"
+	QueueSending  = "sending"
+	QueueSent     = "sent"
+	QueueFailed   = "failed"
+	QueueCanceled = "canceled"
+)
+
+// QueuedMessage é uma mensagem aguardando envio na fila da instância. O ID é
+// o mesmo usado no WhatsApp ao enviar.
+type QueuedMessage struct {
+	ID         string `gorm:"primaryKey"`
+	InstanceID string `gorm:"index"`
+	To         string
+	Priority   int
+	Typing     bool
+	Payload    []byte // waE2E.Message serializada em protobuf
+	Status     string `gorm:"index"`
+	Error      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	SentAt     *time.Time
+}
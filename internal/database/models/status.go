@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// MessageStatusChange é uma mudança de situação de uma mensagem enviada
+// (pending, server_ack, delivered, read ou played).
+type MessageStatusChange struct {
+	ID          uint   `gorm:"primaryKey"`
+	InstanceID  string `gorm:"index"`
+	MessageID   string `gorm:"index"`
+	Chat        string
+	Participant string
+	Status      string
+	Timestamp   time.Time
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Estados de um evento no outbox de webhooks.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed" // dead-letter: esgotou as tentativas
+)
+
+// WebhookOutbox é um evento de webhook persistido até ser entregue.
+type WebhookOutbox struct {
+	ID          string `gorm:"primaryKey;autoIncrement:false"`
+	EventID     string `gorm:"index"`
+	InstanceID  string `gorm:"index"`
+	Url         string
+	Global      bool // entregue ao webhook global, assinado com o secret global
+	Type        string
+	Payload     string `gorm:"type:text"`
+	Status      string `gorm:"index"`
+	Attempts    int
+	LastError   string    `gorm:"type:text"`
+	NextAttempt time.Time `gorm:"index"`
+	DeliveredAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,136 @@
+package hub
+
+import (
+	"sync"
+
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gedsonn/zaapi/internal/models"
+)
+
+// bufferSize é quantos eventos um assinante pode acumular antes de ser
+// considerado lento e desconectado.
+const bufferSize = 256
+
+// Subscriber recebe os eventos de uma instância (ou de todas) filtrados pela máscara.
+type Subscriber struct {
+	instance string // vazio recebe eventos de todas as instâncias
+	events   chan models.Event
+	lagged   chan struct{}
+	done     chan struct{}
+
+	mu   sync.RWMutex
+	mask dbmodels.WebhookEvent
+}
+
+// Events é o canal de eventos do assinante. É fechado ao cancelar a assinatura.
+func (s *Subscriber) Events() <-chan models.Event {
+	return s.events
+}
+
+// Lagged é fechado quando o assinante é descartado por não consumir os eventos a tempo.
+func (s *Subscriber) Lagged() <-chan struct{} {
+	return s.lagged
+}
+
+// Done é fechado quando o hub é encerrado no desligamento do servidor.
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// SetMask altera os eventos recebidos pelo assinante.
+func (s *Subscriber) SetMask(mask dbmodels.WebhookEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mask = mask
+}
+
+func (s *Subscriber) wants(evt models.Event) bool {
+	if s.instance != "" && s.instance != evt.Instance {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mask.Has(evt.Flag())
+}
+
+// Hub distribui os eventos das instâncias para os assinantes em tempo real.
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[*Subscriber]struct{}
+	done   chan struct{}
+	closed bool
+}
+
+func New() *Hub {
+	return &Hub{
+		subs: make(map[*Subscriber]struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Subscribe cria um assinante. instance vazio assina todas as instâncias;
+// mask zerada recebe todos os eventos.
+func (h *Hub) Subscribe(instance string, mask dbmodels.WebhookEvent) *Subscriber {
+	s := &Subscriber{
+		instance: instance,
+		mask:     mask,
+		events:   make(chan models.Event, bufferSize),
+		lagged:   make(chan struct{}),
+		done:     h.done,
+	}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+
+	return s
+}
+
+// Unsubscribe remove o assinante e fecha o seu canal de eventos.
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+
+	delete(h.subs, s)
+	close(s.events)
+}
+
+// Handle é o maneger.EventHandler do hub. Nunca bloqueia: assinantes com o
+// buffer cheio são removidos e avisados por Lagged.
+func (h *Hub) Handle(_ *maneger.Instancia, evt models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		if !s.wants(evt) {
+			continue
+		}
+
+		select {
+		case s.events <- evt:
+		default:
+			delete(h.subs, s)
+			close(s.lagged)
+			close(s.events)
+		}
+	}
+}
+
+// Close avisa todos os assinantes, atuais e futuros, que o servidor está sendo
+// desligado, para que as conexões de streaming sejam encerradas.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	close(h.done)
+}
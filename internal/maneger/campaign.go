@@ -0,0 +1,594 @@
+package maneger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/google/uuid"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("campanha não encontrada")
+	ErrCampaignState    = errors.New("a campanha não está em um estado que permita essa ação")
+	ErrInvalidCampaign  = errors.New("informe o modelo da mensagem e ao menos um destinatário")
+)
+
+// campaignPoll é o intervalo em que o runner confere se a última mensagem da
+// campanha já saiu da fila.
+const campaignPoll = 500 * time.Millisecond
+
+// Recipient é um destinatário de campanha e as variáveis usadas no modelo.
+type Recipient struct {
+	Phone string            `json:"phone"`
+	Vars  map[string]string `json:"vars,omitempty"`
+}
+
+// CampaignOptions são os dados de uma nova campanha.
+type CampaignOptions struct {
+	Name       string
+	Template   string
+	Recipients []Recipient
+	Queue      QueueOptions
+}
+
+// CampaignProgress conta os destinatários por situação.
+type CampaignProgress struct {
+	Total     int64   `json:"total"`
+	Pending   int64   `json:"pending"`
+	Queued    int64   `json:"queued"`
+	Sent      int64   `json:"sent"`
+	Delivered int64   `json:"delivered"`
+	Read      int64   `json:"read"`
+	Failed    int64   `json:"failed"`
+	Canceled  int64   `json:"canceled"`
+	Percent   float64 `json:"percent"` // destinatários que já saíram da fila
+}
+
+// Campaign é a campanha com o andamento atual.
+type Campaign struct {
+	ID         string           `json:"id"`
+	Instance   string           `json:"instance"`
+	Name       string           `json:"name"`
+	Template   string           `json:"template"`
+	Priority   Priority         `json:"priority"`
+	Typing     *bool            `json:"typing,omitempty"`
+	Status     string           `json:"status"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Progress   CampaignProgress `json:"progress"`
+}
+
+// CampaignRecipient é a situação do envio para um destinatário.
+type CampaignRecipient struct {
+	Phone     string            `json:"phone"`
+	Vars      map[string]string `json:"vars,omitempty"`
+	Status    string            `json:"status"`
+	MessageID string            `json:"message_id,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// campaignRun é um runner em execução. Guardado por ponteiro para que um
+// runner antigo não remova o que o substituiu.
+type campaignRun struct {
+	cancel context.CancelFunc
+	done   chan struct{} // fechado quando o runner termina
+}
+
+// campaignRunners guarda os runners das campanhas em andamento da instância.
+type campaignRunners struct {
+	mu   sync.Mutex
+	runs map[string]*campaignRun
+}
+
+func newCampaignRunners() *campaignRunners {
+	return &campaignRunners{runs: make(map[string]*campaignRun)}
+}
+
+// stop interrompe o runner da campanha, se houver, e espera ele terminar:
+// depois disso nenhum destinatário da campanha entra na fila.
+func (c *campaignRunners) stop(id string) {
+	c.mu.Lock()
+	run, ok := c.runs[id]
+	if ok {
+		run.cancel()
+		delete(c.runs, id)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		<-run.done
+	}
+}
+
+// stopAll interrompe todos os runners sem alterar a situação das campanhas
+// e espera eles terminarem.
+func (c *campaignRunners) stopAll() {
+	c.mu.Lock()
+	runs := make([]*campaignRun, 0, len(c.runs))
+	for id, run := range c.runs {
+		run.cancel()
+		delete(c.runs, id)
+		runs = append(runs, run)
+	}
+	c.mu.Unlock()
+
+	for _, run := range runs {
+		<-run.done
+	}
+}
+
+// CreateCampaign grava a campanha com os destinatários e começa a enviar.
+// Exige banco de dados.
+func (i *Instancia) CreateCampaign(opts CampaignOptions) (Campaign, error) {
+	if opts.Template == "" || len(opts.Recipients) == 0 {
+		return Campaign{}, ErrInvalidCampaign
+	}
+
+	row := dbmodels.Campaign{
+		ID:         uuid.NewString(),
+		InstanceID: i.Id,
+		Name:       opts.Name,
+		Template:   opts.Template,
+		Priority:   int(opts.Queue.Priority),
+		Typing:     opts.Queue.Typing,
+		Status:     dbmodels.CampaignRunning,
+	}
+
+	recipients := make([]dbmodels.CampaignRecipient, 0, len(opts.Recipients))
+	for _, r := range opts.Recipients {
+		vars, err := json.Marshal(r.Vars)
+		if err != nil {
+			return Campaign{}, err
+		}
+		recipients = append(recipients, dbmodels.CampaignRecipient{
+			CampaignID: row.ID,
+			Phone:      r.Phone,
+			Vars:       string(vars),
+			Status:     dbmodels.RecipientPending,
+		})
+	}
+
+	err := database.Instance().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&row).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(recipients, 500).Error
+	})
+	if err != nil {
+		return Campaign{}, err
+	}
+
+	i.startCampaign(row.ID)
+
+	return i.Campaign(row.ID)
+}
+
+// Campaigns lista as campanhas da instância, das mais novas para as mais antigas.
+func (i *Instancia) Campaigns() ([]Campaign, error) {
+	var rows []dbmodels.Campaign
+	err := database.Instance().Where("instance_id = ?", i.Id).Order("created_at DESC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Campaign, 0, len(rows))
+	for _, row := range rows {
+		c, err := campaignFromRow(row)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// Campaign retorna a campanha com o andamento.
+func (i *Instancia) Campaign(id string) (Campaign, error) {
+	row, err := i.campaignRow(id)
+	if err != nil {
+		return Campaign{}, err
+	}
+	return campaignFromRow(row)
+}
+
+// CampaignRecipients lista os destinatários da campanha, opcionalmente
+// filtrando pela situação, e retorna o total encontrado.
+func (i *Instancia) CampaignRecipients(id, status string, limit, offset int) ([]CampaignRecipient, int64, error) {
+	if _, err := i.campaignRow(id); err != nil {
+		return nil, 0, err
+	}
+
+	query := database.Instance().Model(&dbmodels.CampaignRecipient{}).Where("campaign_id = ?", id)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []dbmodels.CampaignRecipient
+	if err := query.Order("id").Limit(limit).Offset(offset).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	list := make([]CampaignRecipient, 0, len(rows))
+	for _, row := range rows {
+		r := CampaignRecipient{
+			Phone:     row.Phone,
+			Status:    row.Status,
+			MessageID: row.MessageID,
+			Error:     row.Error,
+			UpdatedAt: row.UpdatedAt,
+		}
+		if row.Vars != "" {
+			_ = json.Unmarshal([]byte(row.Vars), &r.Vars)
+		}
+		list = append(list, r)
+	}
+	return list, total, nil
+}
+
+// PauseCampaign interrompe o envio. A mensagem que já está na fila ainda sai.
+func (i *Instancia) PauseCampaign(id string) (Campaign, error) {
+	if err := i.setCampaignStatus(id, dbmodels.CampaignPaused, dbmodels.CampaignRunning); err != nil {
+		return Campaign{}, err
+	}
+	i.campaigns.stop(id)
+	return i.Campaign(id)
+}
+
+// ResumeCampaign retoma uma campanha pausada do ponto em que parou.
+func (i *Instancia) ResumeCampaign(id string) (Campaign, error) {
+	if err := i.setCampaignStatus(id, dbmodels.CampaignRunning, dbmodels.CampaignPaused); err != nil {
+		return Campaign{}, err
+	}
+	i.startCampaign(id)
+	return i.Campaign(id)
+}
+
+// CancelCampaign encerra a campanha, retira da fila a mensagem que ainda não
+// saiu e marca os destinatários restantes como cancelados.
+func (i *Instancia) CancelCampaign(id string) (Campaign, error) {
+	err := i.setCampaignStatus(id, dbmodels.CampaignCanceled, dbmodels.CampaignRunning, dbmodels.CampaignPaused)
+	if err != nil {
+		return Campaign{}, err
+	}
+	i.campaigns.stop(id)
+
+	db := database.Instance()
+
+	var queued []dbmodels.CampaignRecipient
+	if err := db.Where("campaign_id = ? AND status = ?", id, dbmodels.RecipientQueued).Find(&queued).Error; err != nil {
+		return Campaign{}, err
+	}
+	for _, r := range queued {
+		i.CancelQueued(r.MessageID)
+	}
+
+	err = db.Model(&dbmodels.CampaignRecipient{}).
+		Where("campaign_id = ? AND status = ?", id, dbmodels.RecipientPending).
+		Update("status", dbmodels.RecipientCanceled).Error
+	if err != nil {
+		return Campaign{}, err
+	}
+
+	return i.Campaign(id)
+}
+
+// resumeCampaigns volta a enviar as campanhas que estavam em andamento na
+// execução anterior.
+func (i *Instancia) resumeCampaigns() {
+	var ids []string
+	err := database.Instance().Model(&dbmodels.Campaign{}).
+		Where("instance_id = ? AND status = ?", i.Id, dbmodels.CampaignRunning).
+		Pluck("id", &ids).Error
+	if err != nil {
+		log.Errorf("Erro ao carregar campanhas da instância %s: %v", i.Id, err)
+		return
+	}
+
+	for _, id := range ids {
+		i.startCampaign(id)
+	}
+}
+
+func (i *Instancia) startCampaign(id string) {
+	i.campaigns.mu.Lock()
+	defer i.campaigns.mu.Unlock()
+
+	if _, ok := i.campaigns.runs[id]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &campaignRun{cancel: cancel, done: make(chan struct{})}
+	i.campaigns.runs[id] = run
+
+	go func() {
+		defer close(run.done)
+		i.runCampaign(ctx, id)
+
+		i.campaigns.mu.Lock()
+		if i.campaigns.runs[id] == run {
+			delete(i.campaigns.runs, id)
+		}
+		i.campaigns.mu.Unlock()
+		cancel()
+	}()
+}
+
+// runCampaign coloca os destinatários na fila um de cada vez, esperando a
+// mensagem anterior sair. Assim a campanha segue o ritmo e os limites da fila
+// e a pausa vale já para o próximo destinatário.
+func (i *Instancia) runCampaign(ctx context.Context, id string) {
+	row, err := i.campaignRow(id)
+	if err != nil {
+		log.Errorf("Erro ao carregar campanha %s: %v", id, err)
+		return
+	}
+
+	opts := QueueOptions{Priority: Priority(row.Priority), Typing: row.Typing}
+	db := database.Instance()
+
+	for ctx.Err() == nil {
+		var r dbmodels.CampaignRecipient
+
+		// Mensagem anterior ainda na fila (inclusive de antes de um reinício).
+		err := db.Where("campaign_id = ? AND status = ?", id, dbmodels.RecipientQueued).First(&r).Error
+		if err == nil {
+			if !i.waitQueued(ctx, r.MessageID) {
+				return
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Errorf("Erro ao consultar campanha %s: %v", id, err)
+			sleep(ctx, notLoggedInPoll)
+			continue
+		}
+
+		err = db.Where("campaign_id = ? AND status = ?", id, dbmodels.RecipientPending).Order("id").First(&r).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			now := time.Now()
+			err := db.Model(&dbmodels.Campaign{}).
+				Where("id = ? AND status = ?", id, dbmodels.CampaignRunning).
+				Updates(map[string]any{"status": dbmodels.CampaignCompleted, "finished_at": &now}).Error
+			if err != nil {
+				log.Errorf("Erro ao concluir campanha %s: %v", id, err)
+			}
+			return
+		}
+		if err != nil {
+			log.Errorf("Erro ao consultar campanha %s: %v", id, err)
+			sleep(ctx, notLoggedInPoll)
+			continue
+		}
+
+		if !i.Client().IsLoggedIn() {
+			sleep(ctx, notLoggedInPoll)
+			continue
+		}
+
+		i.sendCampaignRecipient(ctx, row.Template, opts, &r)
+	}
+}
+
+// sendCampaignRecipient monta o texto do destinatário e o coloca na fila.
+func (i *Instancia) sendCampaignRecipient(ctx context.Context, template string, opts QueueOptions, r *dbmodels.CampaignRecipient) {
+	vars := map[string]string{}
+	if r.Vars != "" {
+		_ = json.Unmarshal([]byte(r.Vars), &vars)
+	}
+	if _, ok := vars["phone"]; !ok {
+		vars["phone"] = r.Phone
+	}
+
+	to, err := i.ResolveRecipient(ctx, r.Phone)
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrNotLoggedIn) {
+			return
+		}
+		if !errors.Is(err, ErrInvalidRecipient) && !errors.Is(err, ErrNotOnWhatsApp) {
+			// Erro de rede: tenta de novo na próxima volta.
+			log.Warnf("Erro ao verificar destinatário %s da campanha %s: %v", r.Phone, r.CampaignID, err)
+			sleep(ctx, notLoggedInPoll)
+			return
+		}
+		updateRecipient(r.ID, dbmodels.RecipientFailed, "", err.Error())
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	item, err := i.Enqueue(to, BuildText(renderTemplate(template, vars), SendOptions{}), opts)
+	if err != nil {
+		updateRecipient(r.ID, dbmodels.RecipientFailed, "", err.Error())
+		return
+	}
+
+	// O destinatário pode ter sido cancelado enquanto a mensagem entrava na fila.
+	if !updateRecipient(r.ID, dbmodels.RecipientQueued, item.ID, "") {
+		i.CancelQueued(item.ID)
+	}
+}
+
+// waitQueued aguarda a mensagem sair da fila. Retorna false se o contexto foi cancelado.
+func (i *Instancia) waitQueued(ctx context.Context, id string) bool {
+	for {
+		item, ok := i.Queued(id)
+		if !ok || (item.Status != dbmodels.QueueQueued && item.Status != dbmodels.QueueSending) {
+			return true
+		}
+		if !sleep(ctx, campaignPoll) {
+			return false
+		}
+	}
+}
+
+// campaignQueued leva o resultado da fila ao destinatário da campanha, se a
+// mensagem for de uma. Chamado por finishQueued.
+func campaignQueued(item *QueueItem) {
+	status := map[string]string{
+		dbmodels.QueueSent:     dbmodels.RecipientSent,
+		dbmodels.QueueFailed:   dbmodels.RecipientFailed,
+		dbmodels.QueueCanceled: dbmodels.RecipientCanceled,
+	}[item.Status]
+	if status == "" {
+		return
+	}
+
+	err := database.Instance().Model(&dbmodels.CampaignRecipient{}).
+		Where("message_id = ? AND status = ?", item.ID, dbmodels.RecipientQueued).
+		Updates(map[string]any{"status": status, "error": item.Error}).Error
+	if err != nil {
+		log.Errorf("Erro ao atualizar destinatário da mensagem %s: %v", item.ID, err)
+	}
+}
+
+// campaignReceipt avança os destinatários para delivered ou read conforme as
+// confirmações do WhatsApp.
+func campaignReceipt(evt *events.Receipt) {
+	var status string
+	var from []string
+
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		status = dbmodels.RecipientDelivered
+		from = []string{dbmodels.RecipientQueued, dbmodels.RecipientSent}
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+		status = dbmodels.RecipientRead
+		from = []string{dbmodels.RecipientQueued, dbmodels.RecipientSent, dbmodels.RecipientDelivered}
+	default:
+		return
+	}
+
+	err := database.Instance().Model(&dbmodels.CampaignRecipient{}).
+		Where("message_id IN ? AND status IN ?", evt.MessageIDs, from).
+		Update("status", status).Error
+	if err != nil {
+		log.Errorf("Erro ao registrar confirmação de campanha: %v", err)
+	}
+}
+
+// updateRecipient muda a situação de um destinatário que ainda está pendente.
+// Retorna false se ele já tinha outra situação, como canceled.
+func updateRecipient(id uint, status, messageID, errMsg string) bool {
+	res := database.Instance().Model(&dbmodels.CampaignRecipient{}).
+		Where("id = ? AND status = ?", id, dbmodels.RecipientPending).
+		Updates(map[string]any{"status": status, "message_id": messageID, "error": errMsg})
+	if res.Error != nil {
+		log.Errorf("Erro ao atualizar destinatário %d: %v", id, res.Error)
+		return false
+	}
+	return res.RowsAffected > 0
+}
+
+// setCampaignStatus muda a situação da campanha se a atual for uma de from.
+func (i *Instancia) setCampaignStatus(id, status string, from ...string) error {
+	if _, err := i.campaignRow(id); err != nil {
+		return err
+	}
+
+	values := map[string]any{"status": status}
+	if status == dbmodels.CampaignCanceled {
+		values["finished_at"] = time.Now()
+	}
+
+	res := database.Instance().Model(&dbmodels.Campaign{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCampaignState
+	}
+	return nil
+}
+
+func (i *Instancia) campaignRow(id string) (dbmodels.Campaign, error) {
+	var row dbmodels.Campaign
+	err := database.Instance().Where("id = ? AND instance_id = ?", id, i.Id).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return row, ErrCampaignNotFound
+	}
+	return row, err
+}
+
+func campaignFromRow(row dbmodels.Campaign) (Campaign, error) {
+	c := Campaign{
+		ID:         row.ID,
+		Instance:   row.InstanceID,
+		Name:       row.Name,
+		Template:   row.Template,
+		Priority:   Priority(row.Priority),
+		Typing:     row.Typing,
+		Status:     row.Status,
+		CreatedAt:  row.CreatedAt,
+		FinishedAt: row.FinishedAt,
+	}
+
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	err := database.Instance().Model(&dbmodels.CampaignRecipient{}).
+		Select("status, count(*) AS count").
+		Where("campaign_id = ?", row.ID).
+		Group("status").
+		Scan(&counts).Error
+	if err != nil {
+		return c, err
+	}
+
+	p := &c.Progress
+	for _, n := range counts {
+		p.Total += n.Count
+		switch n.Status {
+		case dbmodels.RecipientPending:
+			p.Pending = n.Count
+		case dbmodels.RecipientQueued:
+			p.Queued = n.Count
+		case dbmodels.RecipientSent:
+			p.Sent = n.Count
+		case dbmodels.RecipientDelivered:
+			p.Delivered = n.Count
+		case dbmodels.RecipientRead:
+			p.Read = n.Count
+		case dbmodels.RecipientFailed:
+			p.Failed = n.Count
+		case dbmodels.RecipientCanceled:
+			p.Canceled = n.Count
+		}
+	}
+	if p.Total > 0 {
+		p.Percent = float64(p.Total-p.Pending-p.Queued) * 100 / float64(p.Total)
+	}
+
+	return c, nil
+}
+
+var templateVar = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// renderTemplate troca cada {{variavel}} pelo valor do destinatário. Variáveis
+// ausentes ficam vazias.
+func renderTemplate(template string, vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(template, func(m string) string {
+		return vars[templateVar.FindStringSubmatch(m)[1]]
+	})
+}
@@ -0,0 +1,186 @@
+package maneger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{"nome": "Ana", "pedido.id": "42", "phone": "5511999999999"}
+
+	tests := []struct {
+		template, want string
+	}{
+		{"Olá, {{nome}}!", "Olá, Ana!"},
+		{"Pedido {{ pedido.id }} de {{nome}}", "Pedido 42 de Ana"},
+		{"Número {{phone}}", "Número 5511999999999"},
+		{"Oi {{sobrenome}}.", "Oi ."},
+		{"Sem variáveis", "Sem variáveis"},
+		{"{nome} e {{ }}", "{nome} e {{ }}"},
+	}
+
+	for _, tt := range tests {
+		if got := renderTemplate(tt.template, vars); got != tt.want {
+			t.Errorf("renderTemplate(%q) = %q, esperado %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+// seedCampaign grava uma campanha com um destinatário em cada situação dada.
+func seedCampaign(t *testing.T, id, status string, recipients ...string) {
+	t.Helper()
+
+	db := database.Instance()
+	if err := db.Create(&dbmodels.Campaign{ID: id, InstanceID: "teste", Template: "oi", Status: status}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for _, st := range recipients {
+		r := dbmodels.CampaignRecipient{CampaignID: id, Phone: "5511999999999", Status: st}
+		if err := db.Create(&r).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+}
+
+func recipientStatuses(t *testing.T, id string) map[string]int {
+	t.Helper()
+
+	var rows []dbmodels.CampaignRecipient
+	if err := database.Instance().Where("campaign_id = ?", id).Find(&rows).Error; err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	got := map[string]int{}
+	for _, r := range rows {
+		got[r.Status]++
+	}
+	return got
+}
+
+func TestCampaignProgress(t *testing.T) {
+	openTestDatabase(t)
+	seedCampaign(t, "c1", dbmodels.CampaignRunning,
+		dbmodels.RecipientPending, dbmodels.RecipientPending, dbmodels.RecipientQueued,
+		dbmodels.RecipientSent, dbmodels.RecipientDelivered, dbmodels.RecipientRead,
+		dbmodels.RecipientFailed, dbmodels.RecipientCanceled)
+
+	i := &Instancia{Id: "teste"}
+	c, err := i.Campaign("c1")
+	if err != nil {
+		t.Fatalf("Campaign: %v", err)
+	}
+
+	want := CampaignProgress{Total: 8, Pending: 2, Queued: 1, Sent: 1, Delivered: 1, Read: 1, Failed: 1, Canceled: 1, Percent: 62.5}
+	if c.Progress != want {
+		t.Fatalf("progresso = %+v, esperado %+v", c.Progress, want)
+	}
+
+	if _, err := i.Campaign("outra"); !errors.Is(err, ErrCampaignNotFound) {
+		t.Fatalf("Campaign de id desconhecido = %v, esperado %v", err, ErrCampaignNotFound)
+	}
+}
+
+func TestCampaignTransitions(t *testing.T) {
+	i := newTestInstance(t)
+	openTestDatabase(t)
+
+	c, err := i.CreateCampaign(CampaignOptions{
+		Template:   "Olá, {{nome}}",
+		Recipients: []Recipient{{Phone: "5511999999999", Vars: map[string]string{"nome": "Ana"}}, {Phone: "5511888888888"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateCampaign: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		action func(string) (Campaign, error)
+		want   string
+		err    error
+	}{
+		{"pausar", i.PauseCampaign, dbmodels.CampaignPaused, nil},
+		{"pausar de novo", i.PauseCampaign, "", ErrCampaignState},
+		{"retomar", i.ResumeCampaign, dbmodels.CampaignRunning, nil},
+		{"retomar em andamento", i.ResumeCampaign, "", ErrCampaignState},
+		{"cancelar", i.CancelCampaign, dbmodels.CampaignCanceled, nil},
+		{"retomar cancelada", i.ResumeCampaign, "", ErrCampaignState},
+		{"cancelar de novo", i.CancelCampaign, "", ErrCampaignState},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.action(c.ID)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: erro = %v, esperado %v", tt.name, err, tt.err)
+		}
+		if err == nil && got.Status != tt.want {
+			t.Fatalf("%s: situação = %s, esperado %s", tt.name, got.Status, tt.want)
+		}
+	}
+
+	if _, err := i.PauseCampaign("outra"); !errors.Is(err, ErrCampaignNotFound) {
+		t.Fatalf("PauseCampaign de id desconhecido = %v, esperado %v", err, ErrCampaignNotFound)
+	}
+}
+
+// TestCancelCampaignStopsRunner garante que o cancelamento espera o runner
+// terminar e cancela a mensagem na fila e os destinatários pendentes.
+func TestCancelCampaignStopsRunner(t *testing.T) {
+	withQueueConfig(t, config.QueueConfig{})
+	i := newTestInstance(t)
+	openTestDatabase(t)
+
+	item := enqueue(t, i, QueueOptions{})
+	seedCampaign(t, "c1", dbmodels.CampaignRunning, dbmodels.RecipientPending, dbmodels.RecipientSent)
+	queued := dbmodels.CampaignRecipient{CampaignID: "c1", Phone: "5511777777777", Status: dbmodels.RecipientQueued, MessageID: item.ID}
+	if err := database.Instance().Create(&queued).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	i.startCampaign("c1")
+	i.campaigns.mu.Lock()
+	run := i.campaigns.runs["c1"]
+	i.campaigns.mu.Unlock()
+
+	var c Campaign
+	withTimeout(t, "CancelCampaign", func() (err error) {
+		c, err = i.CancelCampaign("c1")
+		return err
+	})
+
+	select {
+	case <-run.done:
+	default:
+		t.Fatal("CancelCampaign retornou com o runner ainda em execução")
+	}
+
+	if got, _ := i.Queued(item.ID); got.Status != dbmodels.QueueCanceled {
+		t.Fatalf("mensagem da campanha = %s, esperado %s", got.Status, dbmodels.QueueCanceled)
+	}
+	want := CampaignProgress{Total: 3, Sent: 1, Canceled: 2, Percent: 100}
+	if c.Status != dbmodels.CampaignCanceled || c.Progress != want {
+		t.Fatalf("campanha = %s %+v, esperado canceled %+v", c.Status, c.Progress, want)
+	}
+}
+
+func TestUpdateRecipientOnlyPending(t *testing.T) {
+	openTestDatabase(t)
+	seedCampaign(t, "c1", dbmodels.CampaignCanceled, dbmodels.RecipientPending, dbmodels.RecipientCanceled)
+
+	var rows []dbmodels.CampaignRecipient
+	database.Instance().Order("id").Find(&rows)
+
+	if !updateRecipient(rows[0].ID, dbmodels.RecipientQueued, "msg-1", "") {
+		t.Fatal("updateRecipient não atualizou um destinatário pendente")
+	}
+	if updateRecipient(rows[1].ID, dbmodels.RecipientQueued, "msg-2", "") {
+		t.Fatal("updateRecipient colocou na fila um destinatário cancelado")
+	}
+
+	want := map[string]int{dbmodels.RecipientQueued: 1, dbmodels.RecipientCanceled: 1}
+	if got := recipientStatuses(t, "c1"); len(got) != len(want) || got[dbmodels.RecipientQueued] != 1 || got[dbmodels.RecipientCanceled] != 1 {
+		t.Fatalf("situações = %v, esperado %v", got, want)
+	}
+}
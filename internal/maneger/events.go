@@ -0,0 +1,42 @@
+package maneger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/models"
+	"github.com/google/uuid"
+)
+
+// EventHandler recebe os eventos normalizados de todas as instâncias.
+type EventHandler func(i *Instancia, evt models.Event)
+
+var (
+	handlersMu sync.RWMutex
+	handlers   []EventHandler
+)
+
+// OnEvent registra um consumidor de eventos (webhook, websocket, armazenamento...).
+func OnEvent(h EventHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers = append(handlers, h)
+}
+
+// emit entrega o evento a todos os consumidores registrados.
+func (i *Instancia) emit(kind models.EventType, data any) {
+	evt := models.Event{
+		ID:        uuid.NewString(),
+		Type:      kind,
+		Instance:  i.Id,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+
+	for _, h := range handlers {
+		h(i, evt)
+	}
+}
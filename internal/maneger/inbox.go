@@ -0,0 +1,98 @@
+package maneger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	// maxPendingDownloads limita os anexos aguardando download por instância.
+	// Acima disso a mensagem é entregue sem o arquivo.
+	maxPendingDownloads = 100
+
+	// mediaDownloadTimeout limita o download de um anexo recebido.
+	mediaDownloadTimeout = 2 * time.Minute
+)
+
+// inboxItem é uma mensagem recebida aguardando entrega.
+type inboxItem struct {
+	evt      *events.Message
+	msg      models.Message
+	download bool
+}
+
+// inbox entrega as mensagens recebidas na ordem de chegada por uma goroutine
+// própria, para que o download dos anexos não segure o handler de eventos do
+// whatsmeow. A goroutine termina quando não há mais mensagens.
+type inbox struct {
+	mu        sync.Mutex
+	items     []inboxItem
+	downloads int // itens de items com download
+	running   bool
+}
+
+func newInbox() *inbox {
+	return &inbox{}
+}
+
+// push adiciona a mensagem e retorna true se o worker precisa ser iniciado.
+// Com downloads demais pendentes, a mensagem segue sem o anexo.
+func (b *inbox) push(item inboxItem) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if item.download {
+		if b.downloads >= maxPendingDownloads {
+			log.Warnf("Muitos anexos aguardando download; a mensagem %s será entregue sem o arquivo", item.msg.ID)
+			item.download = false
+		} else {
+			b.downloads++
+		}
+	}
+	b.items = append(b.items, item)
+
+	if b.running {
+		return false
+	}
+	b.running = true
+	return true
+}
+
+// pop retira a próxima mensagem. Retorna false e marca o worker como parado
+// quando não há mais nenhuma.
+func (b *inbox) pop() (inboxItem, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.items) == 0 {
+		b.running = false
+		return inboxItem{}, false
+	}
+
+	item := b.items[0]
+	b.items[0] = inboxItem{}
+	b.items = b.items[1:]
+	if item.download {
+		b.downloads--
+	}
+	return item, true
+}
+
+// deliverMessages baixa os anexos, guarda e entrega as mensagens do inbox.
+func (i *Instancia) deliverMessages() {
+	for {
+		item, ok := i.inbox.pop()
+		if !ok {
+			return
+		}
+
+		if item.download {
+			i.downloadMedia(item.evt, &item.msg)
+		}
+		i.deliverMessage(item.msg)
+	}
+}
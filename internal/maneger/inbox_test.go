@@ -0,0 +1,85 @@
+package maneger
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gedsonn/zaapi/internal/models"
+)
+
+func TestInboxOrderAndWorker(t *testing.T) {
+	b := newInbox()
+
+	if !b.push(inboxItem{msg: models.Message{ID: "1"}}) {
+		t.Fatal("o primeiro push não pediu um worker")
+	}
+	if b.push(inboxItem{msg: models.Message{ID: "2"}}) {
+		t.Fatal("push pediu um segundo worker")
+	}
+
+	for _, want := range []string{"1", "2"} {
+		item, ok := b.pop()
+		if !ok || item.msg.ID != want {
+			t.Fatalf("pop = %q, %v, esperado %q", item.msg.ID, ok, want)
+		}
+	}
+	if _, ok := b.pop(); ok {
+		t.Fatal("pop de um inbox vazio")
+	}
+
+	// Com o worker parado, o próximo push inicia outro.
+	if !b.push(inboxItem{msg: models.Message{ID: "3"}}) {
+		t.Fatal("push após o worker parar não pediu um worker")
+	}
+}
+
+func TestInboxLimitsDownloads(t *testing.T) {
+	b := newInbox()
+	for n := range maxPendingDownloads + 1 {
+		b.push(inboxItem{msg: models.Message{ID: fmt.Sprint(n)}, download: true})
+	}
+
+	if b.downloads != maxPendingDownloads {
+		t.Fatalf("%d downloads pendentes, esperado %d", b.downloads, maxPendingDownloads)
+	}
+	if last := b.items[len(b.items)-1]; last.download {
+		t.Fatal("a mensagem acima do limite manteve o download")
+	}
+
+	for {
+		if _, ok := b.pop(); !ok {
+			break
+		}
+	}
+	if b.downloads != 0 {
+		t.Fatalf("%d downloads pendentes após esvaziar, esperado 0", b.downloads)
+	}
+}
+
+func TestDeliverMessagesKeepsOrder(t *testing.T) {
+	i := newTestInstance(t)
+
+	got := make(chan string, 8)
+	onEvent(t, func(inst *Instancia, evt models.Event) {
+		if inst == i && evt.Type == models.EventMessage {
+			got <- evt.Data.(models.Message).ID
+		}
+	})
+
+	for _, id := range []string{"a", "b", "c"} {
+		i.inbox.push(inboxItem{msg: models.Message{ID: id, Instance: i.Id}})
+	}
+	i.deliverMessages()
+	close(got)
+
+	var ids []string
+	for id := range got {
+		ids = append(ids, id)
+	}
+	if fmt.Sprint(ids) != "[a b c]" {
+		t.Fatalf("mensagens entregues = %v, esperado [a b c]", ids)
+	}
+	if i.inbox.running {
+		t.Fatal("o worker continua marcado como em execução")
+	}
+}
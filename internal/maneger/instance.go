@@ -3,6 +3,7 @@ package maneger
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -10,6 +11,12 @@ import (
 	"time"
 
 	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/models"
+	"github.com/gedsonn/zaapi/internal/ratelimit"
+	"github.com/goccy/go-yaml"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/skip2/go-qrcode"
 	"go.mau.fi/whatsmeow"
@@ -21,51 +28,148 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// Eventos do fluxo de login. QREventCode traz um novo QR code; os demais encerram o fluxo.
+const (
+	QREventCode     = "code"
+	QREventPairCode = "pair_code" // código de pareamento por telefone gerado
+	QREventSuccess  = "success"
+	QREventTimeout  = "timeout"
+	QREventError    = "error"
+)
+
+// ErrQRPending indica que um novo fluxo de QR code foi iniciado e o código ainda não chegou.
+var ErrQRPending = errors.New("solicitando novo QR code, tente novamente em alguns segundos")
+
 // QRCodeEvent representa os dados de um evento de QR code para login.
 type QRCodeEvent struct {
-	Code   string
-	Base64 string
+	Event  string `json:"event"`
+	Code   string `json:"code,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+	Error  string `json:"error,omitempty"`
 }
 
 // Instancia gerencia uma única sessão/conexão com o WhatsApp.
 type Instancia struct {
-	Id     string
-	Client *whatsmeow.Client
-	Mu     sync.RWMutex // Protege o acesso concorrente à instância
+	Id        string
+	Name      string
+	Token     string // token de acesso às rotas da instância
+	CreatedAt time.Time
+	Mu        sync.RWMutex // Protege o acesso concorrente à instância
+
+	// client é trocado pelo Logout; leia sempre por Client.
+	client atomic.Pointer[whatsmeow.Client]
+
+	// container é o store.db da instância, fechado ao remover a sessão, ou o
+	// store compartilhado (sharedStore), que fica aberto.
+	container   *sqlstore.Container
+	sharedStore bool
+
+	// Estado da conexão e as últimas transições. Veja setState.
+	stateMu    sync.RWMutex
+	state      models.State
+	stateSince time.Time
+	history    []models.Transition
+
+	// Supervisor de reconexão. Veja supervise.
+	superviseStop     context.CancelFunc
+	wakeup            chan struct{}
+	reconnectAttempts atomic.Int32 // Falhas seguidas desde o último Connected.
+	ReconnectFailed   atomic.Bool  // Se true, o supervisor desistiu de reconectar.
 
 	// Flags atômicas para um estado seguro entre goroutines.
 	Stopped atomic.Bool // Se true, a instância está parada.
 	Listen  atomic.Bool // Se true, o handler de eventos está registrado.
+	qrFlow  atomic.Bool // Se true, há um qrConnectionFlow em andamento.
 
 	// Armazena o último QR code gerado e o tempo de geração.
 	LastQR     *QRCodeEvent
 	LastQRTime time.Time
+
+	// Último código de pareamento por telefone e o tempo de geração.
+	LastPairCode     string
+	LastPairCodeTime time.Time
+
+	// Fila de envio, mensagens agendadas, campanhas em andamento e situação
+	// das mensagens enviadas. Veja Enqueue, Schedule, CreateCampaign e
+	// MessageStatus.
+	queue     *sendQueue
+	sched     *scheduler
+	campaigns *campaignRunners
+	statuses  *statusTracker
+
+	// Mensagens recebidas aguardando o download do anexo. Veja handleMessage.
+	inbox *inbox
+
+	// Limites de envio próprios da instância (nil usa os globais) e o
+	// limiter criado a partir deles. Veja rateLimiter.
+	RateLimit    *config.Limits
+	limiter      *ratelimit.Limiter
+	limiterReady bool
+
+	// Webhook da instância, máscara de eventos entregues a ele e o secret
+	// usado na assinatura (vazio usa o secret global).
+	Webhook       string
+	WebhookEvents dbmodels.WebhookEvent
+	WebhookSecret string
 }
 
+// InstaciaYml é o estado da instância persistido em sessions/<id>/session.yml.
 type InstaciaYml struct {
-	Id      string
-	Listen  bool
-	Stopped bool
+	Id            string
+	Name          string
+	Token         string
+	CreatedAt     time.Time
+	Number        string
+	Device        string // JID do aparelho no store do whatsmeow
+	Listen        bool
+	Stopped       bool
+	Webhook       string
+	WebhookEvents int
+	WebhookSecret string
+	RateLimit     *config.Limits
 }
 
 // Start inicia a conexão da instância com o WhatsApp.
 func (i *Instancia) Start() error {
+	// As transições são anunciadas depois de liberar i.Mu (veja setState).
+	var changes []models.Transition
+	defer func() { i.announce(changes...) }()
+
 	i.Mu.Lock()
 	defer i.Mu.Unlock()
 
+	record := func(to models.State, reason string) {
+		if t, ok := i.transition(to, reason); ok {
+			changes = append(changes, t)
+		}
+	}
+
 	if !i.Stopped.Load() {
 		return fmt.Errorf("instancia já está online")
 	}
 
 	i.Stopped.Store(false)
 	i.ensureListener()
+	i.startSupervisor()
+
+	if err := i.save(); err != nil {
+		log.Errorf("Erro ao salvar session.yml da instância %s: %v", i.Id, err)
+	}
 
 	// Se o cliente não estiver logado, inicia o fluxo de conexão via QR code.
 	// Caso contrário, apenas conecta.
-	if i.Client.Store.ID == nil {
-		go i.qrConnectionFlow()
+	if i.Client().Store.ID == nil {
+		record(models.StateAwaitingQR, "aguardando leitura do QR code")
+		i.startQRFlow()
 	} else {
-		if err := i.Client.Connect(); err != nil {
+		record(models.StateConnecting, "iniciada")
+		if err := i.Client().Connect(); err != nil {
+			// O supervisor continua tentando em segundo plano.
+			record(models.StateDisconnected, err.Error())
+			select {
+			case i.wakeup <- struct{}{}:
+			default:
+			}
 			return err
 		}
 	}
@@ -73,26 +177,62 @@ func (i *Instancia) Start() error {
 	return nil
 }
 
+// startQRFlow inicia o qrConnectionFlow em uma goroutine, a menos que já
+// exista um em andamento. Um segundo fluxo chamaria GetQRChannel com o
+// cliente já conectado e encerraria o primeiro com erro.
+func (i *Instancia) startQRFlow() bool {
+	if !i.qrFlow.CompareAndSwap(false, true) {
+		return false
+	}
+	go func() {
+		defer i.qrFlow.Store(false)
+		i.qrConnectionFlow()
+	}()
+	return true
+}
+
 // qrConnectionFlow gerencia o ciclo de vida da conexão por QR code.
-// Esta função é executada em uma goroutine para não bloquear.
+// Use startQRFlow para executá-la.
 func (i *Instancia) qrConnectionFlow() {
 	// Se já estiver conectado ou logado, não faz nada.
-	if i.Client.IsConnected() && i.Client.IsLoggedIn() {
+	if i.Client().IsConnected() && i.Client().IsLoggedIn() {
 		return
 	}
 
 	// 1. Solicita o canal de eventos de QR code ANTES de conectar.
 	// A biblioteca whatsmeow exige que GetQRChannel seja chamado antes de Connect.
-	qrChan, err := i.Client.GetQRChannel(context.Background())
+	qrChan, err := i.Client().GetQRChannel(context.Background())
 	if err != nil {
 		log.Errorf("Erro ao obter QR channel: %v", err)
+		i.setState(models.StateDisconnected, err.Error())
+		i.emit(models.EventQR, &QRCodeEvent{Event: QREventError, Error: err.Error()})
+		return
+	}
+
+	// Um fluxo reiniciado após um timeout parte de "disconnected". O Stop
+	// pode ter chegado antes: ele trava i.Mu, então a checagem e a transição
+	// não se intercalam com ele.
+	i.Mu.RLock()
+	stopped := i.Stopped.Load()
+	t, changed := models.Transition{}, false
+	if !stopped {
+		t, changed = i.transition(models.StateAwaitingQR, "aguardando leitura do QR code")
+	}
+	i.Mu.RUnlock()
+
+	if changed {
+		i.announce(t)
+	}
+	if stopped {
 		return
 	}
 
 	// 2. Conecta ao WhatsApp.
 	// Esta chamada irá bloquear até que a conexão seja estabelecida ou falhe.
-	if err := i.Client.Connect(); err != nil {
+	if err := i.Client().Connect(); err != nil {
 		log.Errorf("Erro ao conectar: %v", err)
+		i.setState(models.StateDisconnected, err.Error())
+		i.emit(models.EventQR, &QRCodeEvent{Event: QREventError, Error: err.Error()})
 		return
 	}
 
@@ -109,18 +249,36 @@ func (i *Instancia) qrConnectionFlow() {
 			}
 			encoded := base64.StdEncoding.EncodeToString(png)
 
+			qr := &QRCodeEvent{Event: QREventCode, Code: evt.Code, Base64: encoded}
+
 			i.Mu.Lock()
-			i.LastQR = &QRCodeEvent{Code: evt.Code, Base64: encoded}
+			i.LastQR = qr
 			i.LastQRTime = time.Now()
 			i.Mu.Unlock()
 
+			i.emit(models.EventQR, qr)
+
 		case "timeout", "error", "success":
 			// O fluxo de QR terminou (expirou, deu erro ou teve sucesso).
 			// A goroutine será encerrada pois o canal será fechado pela biblioteca.
 			log.Infof("Evento de login: %s", evt.Event)
 			i.Mu.Lock()
 			i.LastQR = nil // Limpa o QR code antigo.
+			i.LastPairCode = ""
 			i.Mu.Unlock()
+
+			end := &QRCodeEvent{Event: evt.Event}
+			if evt.Error != nil {
+				end.Error = evt.Error.Error()
+			}
+
+			switch evt.Event {
+			case "timeout":
+				i.setState(models.StateDisconnected, "QR code expirado")
+			case "error":
+				i.setState(models.StateDisconnected, end.Error)
+			}
+			i.emit(models.EventQR, end)
 			return // Encerra a goroutine.
 		}
 	}
@@ -128,6 +286,9 @@ func (i *Instancia) qrConnectionFlow() {
 
 // Stop para a instância e desconecta o cliente do WhatsApp.
 func (i *Instancia) Stop() error {
+	var changes []models.Transition
+	defer func() { i.announce(changes...) }()
+
 	i.Mu.Lock()
 	defer i.Mu.Unlock()
 
@@ -136,13 +297,58 @@ func (i *Instancia) Stop() error {
 	}
 
 	i.Stopped.Store(true)
+	i.stopSupervisor()
 
 	// Desconecta client
-	if i.Client != nil && i.Client.IsConnected() {
-		i.Client.Disconnect()
+	if i.Client() != nil && i.Client().IsConnected() {
+		i.Client().Disconnect()
+	}
+	if t, ok := i.transition(models.StateStopped, "parada"); ok {
+		changes = append(changes, t)
 	}
 
-	return nil
+	return i.save()
+}
+
+// Save grava o estado atual da instância no banco de dados, quando houver, e em
+// sessions/<id>/session.yml.
+func (i *Instancia) Save() error {
+	i.Mu.RLock()
+	defer i.Mu.RUnlock()
+	return i.save()
+}
+
+// save grava a instância sem adquirir o lock; quem chama deve segurar i.Mu.
+func (i *Instancia) save() error {
+	s := InstaciaYml{
+		Id:            i.Id,
+		Name:          i.Name,
+		Token:         i.Token,
+		CreatedAt:     i.CreatedAt,
+		Listen:        i.Listen.Load(),
+		Stopped:       i.Stopped.Load(),
+		Webhook:       i.Webhook,
+		WebhookEvents: int(i.WebhookEvents),
+		WebhookSecret: i.WebhookSecret,
+		RateLimit:     i.RateLimit,
+	}
+	if i.Client() != nil && i.Client().Store.ID != nil {
+		s.Number = i.Client().Store.ID.User
+		s.Device = i.Client().Store.ID.String()
+	}
+
+	if database.Available() {
+		if err := i.saveRow(s); err != nil {
+			return err
+		}
+	}
+
+	data, err := yaml.Marshal(&s)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fmt.Sprintf("sessions/%s/session.yml", i.Id), data, 0644)
 }
 
 // ensureListener garante que o handler de eventos seja registrado apenas uma vez.
@@ -150,7 +356,7 @@ func (i *Instancia) ensureListener() {
 	if i.Listen.Load() {
 		return
 	}
-	go i.Client.AddEventHandler(i.handleEvent)
+	go i.Client().AddEventHandler(i.handleEvent)
 	i.Listen.Store(true)
 }
 
@@ -162,23 +368,101 @@ func (i *Instancia) handleEvent(evt interface{}) {
 
 	switch e := evt.(type) {
 	case *events.Message:
+		i.handleMessage(e)
 
 	case *events.PairSuccess:
-		fmt.Printf("%v", e)
+		log.Infof("Instância %s pareada com %s", i.Id, e.ID.User)
+		i.setState(models.StateConnecting, "pareada")
+		if err := i.Save(); err != nil {
+			log.Errorf("Erro ao salvar session.yml da instância %s: %v", i.Id, err)
+		}
+		i.emit(models.EventPairSuccess, map[string]any{
+			"jid":      e.ID.String(),
+			"number":   e.ID.User,
+			"platform": e.Platform,
+		})
+
+	case *events.Connected:
+		data := map[string]any{}
+		if id := i.Client().Store.ID; id != nil {
+			data["jid"] = id.String()
+			data["number"] = id.User
+		}
+		i.emit(models.EventLoggedIn, data)
+		i.reconnectAttempts.Store(0)
+		i.ReconnectFailed.Store(false)
+		i.setState(models.StateConnected, "conectada")
+
+	case *events.Disconnected:
+		i.setState(models.StateDisconnected, "conexão perdida")
+		i.reconnect()
+
+	case *events.KeepAliveTimeout:
+		i.handleKeepAlive(e)
+
+	case *events.StreamReplaced:
+		i.setState(models.StateDisconnected, "sessão aberta em outro cliente")
+
+	case *events.TemporaryBan:
+		i.setState(models.StateBanned, e.String())
+
+	case *events.Receipt:
+		i.emit(models.EventReceipt, ConvertReceipt(e))
+		i.handleReceipt(e)
+		if database.Available() {
+			campaignReceipt(e)
+		}
+
+	case *events.Presence:
+		i.emit(models.EventPresence, ConvertPresence(e))
+
+	case *events.ChatPresence:
+		i.emit(models.EventPresence, ConvertChatPresence(e))
+
+	case *events.LoggedOut:
+		i.setState(models.StateLoggedOut, e.Reason.String())
+		i.emit(models.EventLoggedOut, map[string]any{"reason": e.Reason.String()})
 
 	default:
 
 	}
 }
 
+// SetWebhook altera o webhook da instância e persiste a alteração.
+func (i *Instancia) SetWebhook(url string, mask dbmodels.WebhookEvent, secret string) error {
+	i.Mu.Lock()
+	defer i.Mu.Unlock()
+
+	i.Webhook = url
+	i.WebhookEvents = mask
+	i.WebhookSecret = secret
+
+	return i.save()
+}
+
+// WebhookTarget retorna o webhook da instância e a máscara de eventos.
+func (i *Instancia) WebhookTarget() (string, dbmodels.WebhookEvent) {
+	i.Mu.RLock()
+	defer i.Mu.RUnlock()
+	return i.Webhook, i.WebhookEvents
+}
+
+// Secret retorna o secret de assinatura dos webhooks da instância.
+func (i *Instancia) Secret() string {
+	i.Mu.RLock()
+	defer i.Mu.RUnlock()
+	return i.WebhookSecret
+}
+
 // GetQR retorna o QR code mais recente para login.
-// Se nenhum QR code estiver disponível ou se estiver expirado, ele tenta iniciar um novo fluxo.
+// Se nenhum QR code estiver disponível e não houver fluxo em andamento (o
+// anterior expirou, por exemplo), inicia um novo.
 func (i *Instancia) GetQR() (*QRCodeEvent, error) {
 	if i.Stopped.Load() {
 		return nil, fmt.Errorf("instância(%s) está parada", i.Id)
 	}
 
-	if i.Client.IsLoggedIn() {
+	if i.Client().IsLoggedIn() {
 		return nil, fmt.Errorf("sessão já está conectada")
 	}
 
@@ -186,35 +470,51 @@ func (i *Instancia) GetQR() (*QRCodeEvent, error) {
 	lastQR := i.LastQR
 	i.Mu.RUnlock()
 
-	// Se não houver QR code, ou se o fluxo de QR não estiver ativo, inicia um novo.
-	// A biblioteca fecha o canal de QR em eventos como 'timeout', então precisamos de um novo.
+	// A biblioteca fecha o canal de QR em eventos como 'timeout', então
+	// precisamos de um novo fluxo. Enquanto o atual não gera o primeiro
+	// código, apenas aguarda.
 	if lastQR == nil {
-		go i.qrConnectionFlow()
-		return nil, fmt.Errorf("solicitando novo QR code, tente novamente em alguns segundos")
+		i.startQRFlow()
+		return nil, ErrQRPending
 	}
 
 	return lastQR, nil
 }
 
+// CurrentQR retorna o último QR code gerado, ou nil, sem iniciar um fluxo.
+func (i *Instancia) CurrentQR() *QRCodeEvent {
+	i.Mu.RLock()
+	defer i.Mu.RUnlock()
+	return i.LastQR
+}
+
+// CreateInstance cria uma nova instância com um store vazio em sessions/<id>.
 func CreateInstance(id string) (*Instancia, error) {
 	if len(id) < 1 {
 		return nil, fmt.Errorf("id invalido")
 	}
 
-	ctx := context.Background()
-	path := fmt.Sprintf("sessions/%s", id)
-	err := os.MkdirAll(path, 0755)
+	i, err := openInstance(id)
 	if err != nil {
 		return nil, err
 	}
 
-	dbpath := fmt.Sprintf("file:%s/store.db?_foreign_keys=on", path)
-	container, err := sqlstore.New(ctx, "sqlite3", dbpath, waLog.Noop)
+	i.CreatedAt = time.Now()
+	i.Token = newToken()
+	return i, nil
+}
+
+// openInstance cria sessions/<id>, abre o aparelho da instância (veja
+// openStore) e monta a Instancia parada.
+func openInstance(id string) (*Instancia, error) {
+	ctx := context.Background()
+	path := fmt.Sprintf("sessions/%s", id)
+	err := os.MkdirAll(path, 0755)
 	if err != nil {
 		return nil, err
 	}
 
-	device, err := container.GetFirstDevice(ctx)
+	container, device, shared, err := openStore(ctx, id, path)
 	if err != nil {
 		return nil, err
 	}
@@ -236,18 +536,46 @@ func CreateInstance(id string) (*Instancia, error) {
 		},
 	}
 
-	client := whatsmeow.NewClient(device, waLog.Noop)
+	client := newClient(device)
 
 	Instance := &Instancia{
-		Id:      id,
-		Client:  client,
-		Mu:      sync.RWMutex{},
-		Stopped: atomic.Bool{},
-		Listen:  atomic.Bool{},
+		Id:          id,
+		container:   container,
+		sharedStore: shared,
+		queue:       newSendQueue(),
+		sched:       newScheduler(),
+		campaigns:   newCampaignRunners(),
+		statuses:    newStatusTracker(),
+		inbox:       newInbox(),
+		Mu:          sync.RWMutex{},
+		Stopped:     atomic.Bool{},
+		Listen:      atomic.Bool{},
 	}
 
+	Instance.client.Store(client)
 	Instance.Stopped.Store(true)
 	Instance.Listen.Store(false)
+	if database.Available() {
+		Instance.loadQueue()
+		Instance.resumeCampaigns()
+	}
+
+	Instance.state = models.StateCreated
+	Instance.stateSince = time.Now()
 
 	return Instance, nil
 }
+
+// Client retorna o cliente atual do whatsmeow. O Logout cria um cliente novo
+// para o próximo pareamento, então evite guardar o retorno.
+func (i *Instancia) Client() *whatsmeow.Client {
+	return i.client.Load()
+}
+
+// newClient cria o cliente do whatsmeow. A reconexão automática da biblioteca
+// fica desligada porque o supervisor da instância cuida disso.
+func newClient(device *store.Device) *whatsmeow.Client {
+	client := whatsmeow.NewClient(device, waLog.Noop)
+	client.EnableAutoReconnect = false
+	return client
+}
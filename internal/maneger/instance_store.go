@@ -0,0 +1,139 @@
+package maneger
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// saveRow grava a instância na tabela instances. Chamado pelo save, com i.Mu
+// travado.
+func (i *Instancia) saveRow(s InstaciaYml) error {
+	row, err := rowFromYml(s)
+	if err != nil {
+		return err
+	}
+
+	state, _ := i.State()
+	row.Status = string(state)
+
+	return database.Instance().Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+}
+
+func rowFromYml(s InstaciaYml) (dbmodels.Instance, error) {
+	row := dbmodels.Instance{
+		ID:             s.Id,
+		Name:           s.Name,
+		Token:          s.Token,
+		Number:         sql.NullString{String: s.Number, Valid: s.Number != ""},
+		Device:         s.Device,
+		Listen:         s.Listen,
+		Stopped:        s.Stopped,
+		Webhook:        s.Webhook,
+		Webhook_events: s.WebhookEvents,
+		WebhookSecret:  s.WebhookSecret,
+		CreatedAt:      s.CreatedAt,
+	}
+
+	if s.RateLimit != nil {
+		data, err := json.Marshal(s.RateLimit)
+		if err != nil {
+			return row, err
+		}
+		row.RateLimit = string(data)
+	}
+
+	return row, nil
+}
+
+// syncStatus atualiza o estado, o número e o aparelho da instância no banco.
+// Chamado a cada transição de estado.
+func (i *Instancia) syncStatus() {
+	state, _ := i.State()
+	values := map[string]any{"status": string(state)}
+
+	if i.Client() != nil && i.Client().Store.ID != nil {
+		values["number"] = sql.NullString{String: i.Client().Store.ID.User, Valid: true}
+		values["device_jid"] = i.Client().Store.ID.String()
+	} else if state == models.StateLoggedOut {
+		values["number"] = sql.NullString{}
+		values["device_jid"] = ""
+	}
+
+	err := database.Instance().Model(&dbmodels.Instance{}).Where("id = ?", i.Id).Updates(values).Error
+	if err != nil {
+		log.Errorf("Erro ao atualizar estado da instância %s no banco: %v", i.Id, err)
+	}
+}
+
+// deleteRow remove a instância do banco junto com o que pertence a ela: fila,
+// campanhas, mensagens, situações, webhooks pendentes e chaves de API da
+// instância. Tudo na mesma transação, para não deixar linhas órfãs.
+func deleteRow(id string) error {
+	return database.Instance().Transaction(func(tx *gorm.DB) error {
+		campaigns := tx.Model(&dbmodels.Campaign{}).Select("id").Where("instance_id = ?", id)
+		if err := tx.Where("campaign_id IN (?)", campaigns).Delete(&dbmodels.CampaignRecipient{}).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []any{
+			&dbmodels.Campaign{},
+			&dbmodels.QueuedMessage{},
+			&dbmodels.Message{},
+			&dbmodels.MessageStatusChange{},
+			&dbmodels.WebhookOutbox{},
+			&dbmodels.APIKey{},
+		} {
+			if err := tx.Where("instance_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id = ?", id).Delete(&dbmodels.Instance{}).Error
+	})
+}
+
+// loadRows lê as instâncias salvas no banco, indexadas pelo id.
+func loadRows() (map[string]*InstaciaYml, error) {
+	var rows []dbmodels.Instance
+	if err := database.Instance().Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]*InstaciaYml, len(rows))
+	for _, row := range rows {
+		s := &InstaciaYml{
+			Id:            row.ID,
+			Name:          row.Name,
+			Token:         row.Token,
+			CreatedAt:     row.CreatedAt,
+			Number:        row.Number.String,
+			Device:        row.Device,
+			Listen:        row.Listen,
+			Stopped:       row.Stopped,
+			Webhook:       row.Webhook,
+			WebhookEvents: row.Webhook_events,
+			WebhookSecret: row.WebhookSecret,
+		}
+
+		if row.RateLimit != "" {
+			var limits config.Limits
+			if err := json.Unmarshal([]byte(row.RateLimit), &limits); err != nil {
+				log.Errorf("Limites de envio da instância %s estão corrompidos: %v", row.ID, err)
+			} else {
+				s.RateLimit = &limits
+			}
+		}
+
+		sessions[row.ID] = s
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,161 @@
+package maneger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+)
+
+// writeSessionYml cria sessions/<id>/session.yml com o conteúdo dado.
+func writeSessionYml(t *testing.T, id, content string) {
+	t.Helper()
+
+	dir := filepath.Join("sessions", id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "session.yml"), []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+// TestSyncPrefersDatabase garante que o banco prevalece sobre o session.yml,
+// que só vale para as instâncias que ainda não estão no banco.
+func TestSyncPrefersDatabase(t *testing.T) {
+	t.Chdir(t.TempDir())
+	openTestDatabase(t)
+
+	writeSessionYml(t, "a", "id: a\nname: yml\nstopped: true\n")
+	writeSessionYml(t, "b", "id: b\nname: antiga\nstopped: true\n")
+	for _, row := range []dbmodels.Instance{
+		{ID: "a", Name: "banco", Token: "t-a", Stopped: true},
+		{ID: "c", Name: "sem diretório", Token: "t-c", Stopped: true},
+	} {
+		if err := database.Instance().Create(&row).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	m := EmptyManager()
+	if err := m.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, i := range m.List() {
+			i.close()
+		}
+	})
+
+	tests := []struct {
+		id, name string
+	}{
+		{"a", "banco"},
+		{"b", "antiga"},
+	}
+	for _, tt := range tests {
+		i, ok := m.Get(tt.id)
+		if !ok {
+			t.Fatalf("instância %s não foi restaurada", tt.id)
+		}
+		if i.Name != tt.name {
+			t.Errorf("instância %s com nome %q, esperado %q", tt.id, i.Name, tt.name)
+		}
+	}
+
+	// O store.db da instância c ficaria em sessions/c.
+	if _, ok := m.Get("c"); ok {
+		t.Error("instância sem sessions/c restaurada sem o store")
+	}
+}
+
+// TestDeleteRowRemovesInstanceData garante que apagar uma instância leva
+// junto as linhas dela, e só as dela.
+func TestDeleteRowRemovesInstanceData(t *testing.T) {
+	openTestDatabase(t)
+
+	for _, id := range []string{"a", "b"} {
+		for _, row := range []any{
+			&dbmodels.Instance{ID: id, Token: "t-" + id},
+			&dbmodels.Campaign{ID: "camp-" + id, InstanceID: id},
+			&dbmodels.CampaignRecipient{CampaignID: "camp-" + id, Phone: "5511999999999"},
+			&dbmodels.QueuedMessage{ID: "fila-" + id, InstanceID: id},
+			&dbmodels.Message{InstanceID: id, MessageID: "msg"},
+			&dbmodels.MessageStatusChange{InstanceID: id, MessageID: "msg"},
+			&dbmodels.WebhookOutbox{ID: "evt-" + id, InstanceID: id},
+			&dbmodels.APIKey{ID: "key-" + id, Hash: "hash-" + id, InstanceID: id},
+		} {
+			if err := database.Instance().Create(row).Error; err != nil {
+				t.Fatalf("Create %T: %v", row, err)
+			}
+		}
+	}
+
+	if err := deleteRow("a"); err != nil {
+		t.Fatalf("deleteRow: %v", err)
+	}
+
+	for _, model := range []any{
+		&dbmodels.Instance{},
+		&dbmodels.Campaign{},
+		&dbmodels.CampaignRecipient{},
+		&dbmodels.QueuedMessage{},
+		&dbmodels.Message{},
+		&dbmodels.MessageStatusChange{},
+		&dbmodels.WebhookOutbox{},
+		&dbmodels.APIKey{},
+	} {
+		var count int64
+		if err := database.Instance().Model(model).Count(&count).Error; err != nil {
+			t.Fatalf("Count %T: %v", model, err)
+		}
+		if count != 1 {
+			t.Errorf("%T: %d linhas, esperado só a da instância b", model, count)
+		}
+	}
+}
+
+// TestSyncSharedStoreCreatesSessionDir garante que, com o store
+// compartilhado, uma instância do banco sem sessions/<id> é restaurada.
+func TestSyncSharedStoreCreatesSessionDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	prev := config.Get()
+	cfg := config.DefaultConfig()
+	cfg.Database.Path = ":memory:"
+	cfg.Whatsapp.Store = StoreDatabase
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(prev) })
+
+	openTestDatabase(t)
+	t.Cleanup(closeSharedStore)
+
+	row := dbmodels.Instance{ID: "c", Name: "só no banco", Token: "t-c", Stopped: true}
+	if err := database.Instance().Create(&row).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	m := EmptyManager()
+	if err := m.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, i := range m.List() {
+			i.close()
+		}
+	})
+
+	i, ok := m.Get("c")
+	if !ok {
+		t.Fatal("instância c não foi restaurada")
+	}
+	if i.Name != row.Name {
+		t.Fatalf("nome %q, esperado %q", i.Name, row.Name)
+	}
+	if _, err := os.Stat(filepath.Join("sessions", "c", "session.yml")); err != nil {
+		t.Fatalf("sessions/c não foi criado: %v", err)
+	}
+}
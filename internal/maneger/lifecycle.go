@@ -0,0 +1,194 @@
+package maneger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/models"
+)
+
+var ErrInvalidSessionID = errors.New("id de sessão inválido")
+
+// Info retorna o resumo da instância exposto pela API.
+func (i *Instancia) Info() models.Instance {
+	i.Mu.RLock()
+	info := models.Instance{
+		ID:        i.Id,
+		Name:      i.Name,
+		CreatedAt: i.CreatedAt,
+	}
+	if id := i.Client().Store.ID; id != nil {
+		info.Number = id.User
+	}
+	i.Mu.RUnlock()
+
+	info.Status, info.StatusSince = i.State()
+	info.Connected = i.Client().IsConnected()
+	info.LoggedIn = i.Client().IsLoggedIn()
+	info.ReconnectFailed = i.ReconnectFailed.Load()
+
+	return info
+}
+
+// Logout desconecta o aparelho da conta do WhatsApp e para a instância. Um
+// novo aparelho é preparado para que a sessão possa ser pareada de novo.
+func (i *Instancia) Logout(ctx context.Context) error {
+	if !i.Client().IsLoggedIn() {
+		return ErrNotLoggedIn
+	}
+
+	if err := i.Client().Logout(ctx); err != nil {
+		return err
+	}
+
+	i.Mu.Lock()
+	i.Client().RemoveEventHandlers()
+	i.client.Store(newClient(i.container.NewDevice()))
+	i.Listen.Store(false)
+	i.LastQR = nil
+	i.LastPairCode = ""
+	i.Mu.Unlock()
+
+	i.setState(models.StateLoggedOut, "user_initiated")
+	i.emit(models.EventLoggedOut, map[string]any{"reason": "user_initiated"})
+
+	return i.Stop()
+}
+
+// close para a instância sem alterar o session.yml e fecha o store.
+func (i *Instancia) close() {
+	// Fora de i.Mu: o runner pode estar emitindo um evento, e os handlers leem a instância.
+	i.campaigns.stopAll()
+
+	i.Mu.Lock()
+	defer i.Mu.Unlock()
+
+	i.Stopped.Store(true)
+	i.stopSupervisor()
+	if i.Client() != nil {
+		i.Client().RemoveEventHandlers()
+		i.Client().Disconnect()
+	}
+	i.Listen.Store(false)
+	i.sched.stop()
+
+	if i.container != nil && !i.sharedStore {
+		if err := i.container.Close(); err != nil {
+			log.Errorf("Erro ao fechar store da instância %s: %v", i.Id, err)
+		}
+	}
+}
+
+// Shutdown desconecta a instância no desligamento do processo. Diferente de
+// Stop, o session.yml mantém o estado atual para que a sessão volte a conectar
+// na próxima execução.
+func (i *Instancia) Shutdown() {
+	if err := i.Save(); err != nil {
+		log.Errorf("Erro ao salvar session.yml da instância %s: %v", i.Id, err)
+	}
+	i.close()
+}
+
+// List retorna as instâncias em memória, da mais antiga para a mais nova.
+func (m *Manager) List() []*Instancia {
+	m.Mu.Lock()
+	list := make([]*Instancia, 0, len(m.Instacias))
+	for _, i := range m.Instacias {
+		list = append(list, i)
+	}
+	m.Mu.Unlock()
+
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].CreatedAt.Equal(list[b].CreatedAt) {
+			return list[a].Id < list[b].Id
+		}
+		return list[a].CreatedAt.Before(list[b].CreatedAt)
+	})
+
+	return list
+}
+
+// Delete remove a instância do manager e apaga sessions/<id>. Se a sessão
+// estiver pareada, tenta antes desconectar o aparelho da conta.
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	dir, err := sessionDir(id)
+	if err != nil {
+		return err
+	}
+
+	i, ok := m.Get(id)
+	if !ok {
+		return fmt.Errorf("instância %s não encontrada", id)
+	}
+
+	// O Logout já remove o aparelho do store.
+	loggedOut := false
+	if i.Client().IsLoggedIn() {
+		if err := i.Client().Logout(ctx); err != nil {
+			log.Warnf("Erro ao desconectar aparelho da instância %s: %v", id, err)
+		} else {
+			loggedOut = true
+		}
+	}
+
+	// No store compartilhado o aparelho não some com o diretório da sessão:
+	// sem o Logout, ele é removido aqui, e a instância só é apagada se isso
+	// der certo.
+	if i.sharedStore && !loggedOut && i.Client().Store.ID != nil {
+		if err := i.Client().Store.Delete(ctx); err != nil {
+			return fmt.Errorf("erro ao remover aparelho da instância %s do store: %w", id, err)
+		}
+	}
+
+	m.Remove(id)
+	i.close()
+
+	if database.Available() {
+		if err := deleteRow(id); err != nil {
+			log.Errorf("Erro ao remover instância %s do banco: %v", id, err)
+		}
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// sessionDir retorna sessions/<id>, recusando ids que escapem do diretório.
+func sessionDir(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || filepath.Base(id) != id {
+		return "", ErrInvalidSessionID
+	}
+	return filepath.Join("sessions", id), nil
+}
+
+// Shutdown desconecta todas as instâncias em paralelo, aguardando até o prazo do contexto.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, i := range m.List() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.Shutdown()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		closeSharedStore()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
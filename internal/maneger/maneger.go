@@ -1,25 +1,20 @@
 package maneger
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
+	"sort"
 	"sync"
-	"sync/atomic"
 
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/models"
 	"github.com/goccy/go-yaml"
-	_ "github.com/mattn/go-sqlite3"
-	"go.mau.fi/whatsmeow"
-	"go.mau.fi/whatsmeow/proto/waCompanionReg"
-	"go.mau.fi/whatsmeow/store"
-	"go.mau.fi/whatsmeow/store/sqlstore"
-	waLog "go.mau.fi/whatsmeow/util/log"
-	"google.golang.org/protobuf/proto"
 )
 
-
 type Manager struct {
 	Instacias map[string]*Instancia
 	Mu        sync.Mutex
@@ -65,96 +60,146 @@ func (m *Manager) Remove(id string) {
 	delete(m.Instacias, id)
 }
 
+// Sync restaura as instâncias salvas, registra cada uma no manager e
+// reconecta as que não estavam paradas quando foram salvas. O banco de dados é
+// a fonte da verdade; o session.yml só é lido para as instâncias em sessions/
+// que ainda não estão no banco, que passam a estar no primeiro save.
 func (m *Manager) Sync() error {
 	dirs, err := os.ReadDir("sessions")
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
 
-	
+	var rows map[string]*InstaciaYml
+	if database.Available() {
+		rows, err = loadRows()
+		if err != nil {
+			log.Errorf("Erro ao ler instâncias do banco, usando session.yml: %v", err)
+		}
+	}
 
+	onDisk := make(map[string]fs.DirEntry, len(dirs))
 	for _, d := range dirs {
-		
-		if !d.IsDir() {
-			continue
+		if d.IsDir() {
+			onDisk[d.Name()] = d
 		}
+	}
 
-		fmt.Printf("%v", d)
+	ids := make([]string, 0, len(rows)+len(onDisk))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	for id := range onDisk {
+		if _, ok := rows[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
 
-		id := d.Name()
+	for _, id := range ids {
+		if _, err := sessionDir(id); err != nil {
+			log.Warnf("Instância %q do banco tem um id inválido, ignorada", id)
+			continue
+		}
 
-		path := fmt.Sprintf("sessions/%s/session.yml", id)
+		d, inDir := onDisk[id]
+		s, inDB := rows[id]
 
-		data, err := os.ReadFile(path)
-		if err != nil {
-			if errors.Is(err, fs.ErrNotExist) {
+		switch {
+		case !inDB:
+			if s, err = readSessionYml(id); err != nil {
+				log.Errorf("Erro ao ler session.yml da instância %s: %v", id, err)
 				continue
 			}
-			return err
-		}
-
-		var s InstaciaYml
-		if err := yaml.Unmarshal(data, &s); err != nil {
-			return err
+		case !inDir && !sharedStoreEnabled():
+			// Com whatsapp.store: session o aparelho fica em
+			// sessions/<id>/store.db: sem ele não há o que restaurar. No store
+			// compartilhado o diretório é criado ao abrir a instância.
+			log.Warnf("Instância %s está no banco mas não tem sessions/%s, ignorada", id, id)
+			continue
 		}
 
-		
+		m.restore(id, s, d)
 	}
 
 	return nil
 }
 
-func (m *Manager) RestoreInstance(id string) (*Instancia, error) {
-	ctx := context.Background()
-	path := fmt.Sprintf("sessions/%s", id)
-	err := os.MkdirAll(path, 0755)
+// restore abre a instância id com os dados s (nil para sessões antigas, sem
+// session.yml) e a reconecta se ela não estava parada. d é o diretório
+// sessions/<id>, se existir.
+func (m *Manager) restore(id string, s *InstaciaYml, d fs.DirEntry) {
+	i, err := m.RestoreInstance(id)
 	if err != nil {
-		return nil, err
+		log.Errorf("Erro ao restaurar instância %s: %v", id, err)
+		return
 	}
 
-	dbpath := fmt.Sprintf("file:%s/store.db?_foreign_keys=on", path)
-	container, err := sqlstore.New(ctx, "sqlite3", dbpath, waLog.Noop)
-	if err != nil {
-		return nil, err
+	m.Add(i)
+
+	if err := i.loadSchedules(); err != nil {
+		log.Errorf("Erro ao ler schedules.yml da instância %s: %v", id, err)
 	}
 
-	device, err := container.GetFirstDevice(ctx)
-	if err != nil {
-		return nil, err
+	// Sessões antigas não tem session.yml: só reconecta as que já estão pareadas.
+	if s == nil {
+		s = &InstaciaYml{Id: id, Stopped: i.Client().Store.ID == nil}
 	}
 
-	// Identidade do aparelho
-	store.DeviceProps = &waCompanionReg.DeviceProps{
-		Os:           proto.String("Windows"),                  //windowns
-		PlatformType: waCompanionReg.DeviceProps_CHROME.Enum(), //chrome
-		Version: &waCompanionReg.DeviceProps_AppVersion{
-			Primary:   proto.Uint32(118),
-			Secondary: proto.Uint32(0),
-			Tertiary:  proto.Uint32(2),
-		},
-		HistorySyncConfig: &waCompanionReg.DeviceProps_HistorySyncConfig{
-			SupportGroupHistory:   proto.Bool(false),
-			StorageQuotaMb:        proto.Uint32(0),
-			RecentSyncDaysLimit:   proto.Uint32(0), //não fazer sync
-			SupportCallLogHistory: proto.Bool(false),
-		},
+	if s.CreatedAt.IsZero() && d != nil {
+		if info, err := d.Info(); err == nil {
+			s.CreatedAt = info.ModTime()
+		}
 	}
 
-	
+	// Sessões anteriores à autenticação ganham um token novo.
+	if s.Token == "" {
+		s.Token = newToken()
+	}
 
-	client := whatsmeow.NewClient(device, waLog.Noop)
+	i.Name = s.Name
+	i.Token = s.Token
+	i.CreatedAt = s.CreatedAt
+	i.Webhook = s.Webhook
+	i.WebhookEvents = dbmodels.WebhookEvent(s.WebhookEvents)
+	i.WebhookSecret = s.WebhookSecret
+	i.RateLimit = s.RateLimit
+
+	if s.Stopped {
+		i.setState(models.StateStopped, "restaurada parada")
+		if err := i.Save(); err != nil {
+			log.Errorf("Erro ao salvar session.yml da instância %s: %v", id, err)
+		}
+		return
+	}
 
-	Instance := &Instancia{
-		Id:      id,
-		Client:  client,
-		Mu:      sync.RWMutex{},
-		Stopped: atomic.Bool{},
-		Listen:  atomic.Bool{},
+	if err := i.Start(); err != nil {
+		log.Errorf("Erro ao reconectar instância %s: %v", id, err)
+		return
 	}
 
-	Instance.Stopped.Store(true)
-	Instance.Listen.Store(false)
+	log.Infof("Instância %s restaurada", id)
+}
 
-	return Instance, nil
+// readSessionYml lê sessions/<id>/session.yml. Retorna nil se o arquivo não existir.
+func readSessionYml(id string) (*InstaciaYml, error) {
+	data, err := os.ReadFile(fmt.Sprintf("sessions/%s/session.yml", id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var s InstaciaYml
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
 }
 
+// RestoreInstance abre o store existente em sessions/<id>.
+func (m *Manager) RestoreInstance(id string) (*Instancia, error) {
+	return openInstance(id)
+}
@@ -0,0 +1,253 @@
+package maneger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// MediaKind é o tipo de mídia enviada.
+type MediaKind string
+
+const (
+	MediaImage    MediaKind = "image"
+	MediaVideo    MediaKind = "video"
+	MediaAudio    MediaKind = "audio"
+	MediaDocument MediaKind = "document"
+	MediaSticker  MediaKind = "sticker"
+)
+
+var ErrEmptyMedia = errors.New("arquivo de mídia vazio")
+
+// thumbnailSize é a largura máxima da miniatura gerada para imagens.
+const thumbnailSize = 72
+
+// Media descreve um arquivo a ser enviado.
+type Media struct {
+	Kind      MediaKind
+	Data      []byte
+	Mimetype  string
+	Filename  string
+	Caption   string
+	PTT       bool   // áudio como mensagem de voz
+	Thumbnail []byte // JPEG; gerado automaticamente para imagens
+}
+
+// mediaType mapeia o tipo da mídia para as chaves usadas no upload.
+func (k MediaKind) mediaType() whatsmeow.MediaType {
+	switch k {
+	case MediaImage, MediaSticker:
+		return whatsmeow.MediaImage
+	case MediaVideo:
+		return whatsmeow.MediaVideo
+	case MediaAudio:
+		return whatsmeow.MediaAudio
+	default:
+		return whatsmeow.MediaDocument
+	}
+}
+
+// BuildMedia faz o upload do arquivo para o WhatsApp e monta a mensagem do tipo correspondente.
+func (i *Instancia) BuildMedia(ctx context.Context, m Media, opts SendOptions) (*waE2E.Message, error) {
+	if len(m.Data) == 0 {
+		return nil, ErrEmptyMedia
+	}
+
+	if !i.Client().IsLoggedIn() {
+		return nil, ErrNotLoggedIn
+	}
+
+	m.fill()
+
+	up, err := i.Client().Upload(ctx, m.Data, m.Kind.mediaType())
+	if err != nil {
+		return nil, fmt.Errorf("erro ao enviar mídia: %w", err)
+	}
+
+	info := opts.contextInfo()
+
+	switch m.Kind {
+	case MediaImage:
+		return &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+			Caption:       optional(m.Caption),
+			Mimetype:      proto.String(m.Mimetype),
+			JPEGThumbnail: m.Thumbnail,
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			ContextInfo:   info,
+		}}, nil
+
+	case MediaVideo:
+		return &waE2E.Message{VideoMessage: &waE2E.VideoMessage{
+			Caption:       optional(m.Caption),
+			Mimetype:      proto.String(m.Mimetype),
+			JPEGThumbnail: m.Thumbnail,
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			ContextInfo:   info,
+		}}, nil
+
+	case MediaAudio:
+		return &waE2E.Message{AudioMessage: &waE2E.AudioMessage{
+			Mimetype:      proto.String(m.Mimetype),
+			PTT:           proto.Bool(m.PTT),
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			ContextInfo:   info,
+		}}, nil
+
+	case MediaSticker:
+		return &waE2E.Message{StickerMessage: &waE2E.StickerMessage{
+			Mimetype:      proto.String(m.Mimetype),
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			ContextInfo:   info,
+		}}, nil
+
+	default:
+		return &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{
+			Caption:       optional(m.Caption),
+			Title:         proto.String(m.Filename),
+			FileName:      proto.String(m.Filename),
+			Mimetype:      proto.String(m.Mimetype),
+			JPEGThumbnail: m.Thumbnail,
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			ContextInfo:   info,
+		}}, nil
+	}
+}
+
+// fill completa mimetype, nome do arquivo e miniatura quando não foram informados.
+func (m *Media) fill() {
+	if m.Mimetype == "" || m.Mimetype == "application/octet-stream" {
+		m.Mimetype = http.DetectContentType(m.Data)
+	}
+
+	switch m.Kind {
+	case MediaAudio:
+		// O WhatsApp só reproduz mensagens de voz em ogg/opus.
+		if m.PTT || strings.HasSuffix(m.Mimetype, "/ogg") {
+			m.Mimetype = "audio/ogg; codecs=opus"
+		}
+	case MediaSticker:
+		m.Mimetype = "image/webp"
+	case MediaImage:
+		if m.Thumbnail == nil {
+			m.Thumbnail = thumbnail(m.Data)
+		}
+	}
+
+	if m.Filename == "" {
+		m.Filename = "arquivo" + m.Extension()
+	}
+}
+
+// Extension retorna a extensão do arquivo a partir do nome ou do mimetype.
+func (m *Media) Extension() string {
+	if ext := filepath.Ext(m.Filename); ext != "" {
+		return ext
+	}
+
+	mimetype, _, _ := strings.Cut(m.Mimetype, ";")
+	exts, _ := mime.ExtensionsByType(mimetype)
+	if len(exts) > 0 {
+		return exts[0]
+	}
+
+	return ".bin"
+}
+
+// SaveMedia guarda uma cópia da mídia enviada em <media.path>/<sessão>/<id da mensagem>.
+func (i *Instancia) SaveMedia(id string, m Media) (string, error) {
+	if m.Mimetype == "" {
+		m.Mimetype = http.DetectContentType(m.Data)
+	}
+
+	dir := filepath.Join(config.Get().Media.Path, i.Id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, id+m.Extension())
+	if err := os.WriteFile(path, m.Data, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// thumbnail gera uma miniatura JPEG da imagem. Retorna nil se o formato não for suportado.
+func thumbnail(data []byte) []byte {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	b := src.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil
+	}
+
+	w, h := thumbnailSize, b.Dy()*thumbnailSize/b.Dx()
+	if h == 0 {
+		h = 1
+	}
+
+	// Redimensionamento simples por vizinho mais próximo, suficiente para a prévia.
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 60}); err != nil {
+		return nil
+	}
+
+	return buf.Bytes()
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return proto.String(s)
+}
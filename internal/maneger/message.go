@@ -0,0 +1,315 @@
+package maneger
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ConvertMessage converte uma mensagem do whatsmeow para o formato normalizado.
+// Retorna false para mensagens de protocolo que não interessam aos consumidores.
+func ConvertMessage(instance string, evt *events.Message) (models.Message, bool) {
+	info := evt.Info
+
+	m := models.Message{
+		ID:       info.ID,
+		Instance: instance,
+		Type:     models.MessageUnknown,
+		Chat: models.Chat{
+			JID:     info.Chat.String(),
+			Number:  info.Chat.User,
+			IsGroup: info.IsGroup,
+		},
+		Sender: models.Sender{
+			JID:    info.Sender.ToNonAD().String(),
+			Number: info.Sender.User,
+			Name:   info.PushName,
+		},
+		FromMe:    info.IsFromMe,
+		Timestamp: info.Timestamp,
+		Ephemeral: evt.IsEphemeral,
+		ViewOnce:  evt.IsViewOnce,
+	}
+
+	msg := evt.Message
+	if msg == nil {
+		return m, false
+	}
+
+	// Edições e exclusões chegam como ProtocolMessage apontando para a mensagem original.
+	if pm := msg.GetProtocolMessage(); pm != nil {
+		switch pm.GetType() {
+		case waE2E.ProtocolMessage_REVOKE:
+			m.Type = models.MessageRevoke
+			m.TargetID = pm.GetKey().GetID()
+			return m, true
+		case waE2E.ProtocolMessage_MESSAGE_EDIT:
+			m.TargetID = pm.GetKey().GetID()
+			if edited := pm.GetEditedMessage(); edited != nil {
+				fillContent(&m, edited)
+			}
+			m.Type = models.MessageEdit
+			return m, true
+		default:
+			return m, false
+		}
+	}
+
+	fillContent(&m, msg)
+
+	if ci := contextInfo(msg); ci != nil {
+		m.Mentions = ci.GetMentionedJID()
+		m.Forwarded = ci.GetIsForwarded()
+		if id := ci.GetStanzaID(); id != "" {
+			m.Quoted = &models.Quoted{
+				ID:     id,
+				Sender: ci.GetParticipant(),
+				Text:   textOf(ci.GetQuotedMessage()),
+			}
+		}
+	}
+
+	return m, true
+}
+
+// fillContent preenche tipo, texto e anexos a partir do conteúdo da mensagem.
+func fillContent(m *models.Message, msg *waE2E.Message) {
+	switch {
+	case msg.GetConversation() != "":
+		m.Type = models.MessageText
+		m.Text = msg.GetConversation()
+
+	case msg.GetExtendedTextMessage() != nil:
+		m.Type = models.MessageText
+		m.Text = msg.GetExtendedTextMessage().GetText()
+
+	case msg.GetImageMessage() != nil:
+		img := msg.GetImageMessage()
+		m.Type = models.MessageImage
+		m.Text = img.GetCaption()
+		m.Media = &models.Media{
+			Mimetype: img.GetMimetype(),
+			Size:     img.GetFileLength(),
+			Sha256:   hex.EncodeToString(img.GetFileSHA256()),
+		}
+
+	case msg.GetVideoMessage() != nil:
+		vid := msg.GetVideoMessage()
+		m.Type = models.MessageVideo
+		m.Text = vid.GetCaption()
+		m.Media = &models.Media{
+			Mimetype: vid.GetMimetype(),
+			Size:     vid.GetFileLength(),
+			Seconds:  vid.GetSeconds(),
+			Sha256:   hex.EncodeToString(vid.GetFileSHA256()),
+		}
+
+	case msg.GetAudioMessage() != nil:
+		aud := msg.GetAudioMessage()
+		m.Type = models.MessageAudio
+		m.Media = &models.Media{
+			Mimetype: aud.GetMimetype(),
+			Size:     aud.GetFileLength(),
+			Seconds:  aud.GetSeconds(),
+			PTT:      aud.GetPTT(),
+			Sha256:   hex.EncodeToString(aud.GetFileSHA256()),
+		}
+
+	case msg.GetDocumentMessage() != nil:
+		doc := msg.GetDocumentMessage()
+		m.Type = models.MessageDocument
+		m.Text = doc.GetCaption()
+		m.Media = &models.Media{
+			Mimetype: doc.GetMimetype(),
+			Filename: doc.GetFileName(),
+			Size:     doc.GetFileLength(),
+			Sha256:   hex.EncodeToString(doc.GetFileSHA256()),
+		}
+
+	case msg.GetStickerMessage() != nil:
+		st := msg.GetStickerMessage()
+		m.Type = models.MessageSticker
+		m.Media = &models.Media{
+			Mimetype: st.GetMimetype(),
+			Size:     st.GetFileLength(),
+			Sha256:   hex.EncodeToString(st.GetFileSHA256()),
+		}
+
+	case msg.GetLocationMessage() != nil:
+		loc := msg.GetLocationMessage()
+		m.Type = models.MessageLocation
+		m.Location = &models.Location{
+			Latitude:  loc.GetDegreesLatitude(),
+			Longitude: loc.GetDegreesLongitude(),
+			Name:      loc.GetName(),
+			Address:   loc.GetAddress(),
+		}
+
+	case msg.GetLiveLocationMessage() != nil:
+		loc := msg.GetLiveLocationMessage()
+		m.Type = models.MessageLocation
+		m.Text = loc.GetCaption()
+		m.Location = &models.Location{
+			Latitude:  loc.GetDegreesLatitude(),
+			Longitude: loc.GetDegreesLongitude(),
+			Live:      true,
+		}
+
+	case msg.GetContactMessage() != nil:
+		c := msg.GetContactMessage()
+		m.Type = models.MessageContact
+		m.Contacts = []models.Contact{{Name: c.GetDisplayName(), Vcard: c.GetVcard()}}
+
+	case msg.GetContactsArrayMessage() != nil:
+		m.Type = models.MessageContact
+		for _, c := range msg.GetContactsArrayMessage().GetContacts() {
+			m.Contacts = append(m.Contacts, models.Contact{Name: c.GetDisplayName(), Vcard: c.GetVcard()})
+		}
+
+	case msg.GetReactionMessage() != nil:
+		r := msg.GetReactionMessage()
+		m.Type = models.MessageReaction
+		m.Reaction = &models.Reaction{
+			Emoji:    r.GetText(),
+			TargetID: r.GetKey().GetID(),
+		}
+
+	case pollOf(msg) != nil:
+		p := pollOf(msg)
+		m.Type = models.MessagePoll
+		m.Poll = &models.Poll{
+			Name:       p.GetName(),
+			Selectable: p.GetSelectableOptionsCount(),
+		}
+		for _, o := range p.GetOptions() {
+			m.Poll.Options = append(m.Poll.Options, o.GetOptionName())
+		}
+	}
+}
+
+// pollOf retorna a enquete em qualquer uma das versões da mensagem.
+func pollOf(msg *waE2E.Message) *waE2E.PollCreationMessage {
+	switch {
+	case msg.GetPollCreationMessage() != nil:
+		return msg.GetPollCreationMessage()
+	case msg.GetPollCreationMessageV2() != nil:
+		return msg.GetPollCreationMessageV2()
+	case msg.GetPollCreationMessageV3() != nil:
+		return msg.GetPollCreationMessageV3()
+	default:
+		return msg.GetPollCreationMessageV5()
+	}
+}
+
+// contextInfo retorna o ContextInfo (resposta, menções) do conteúdo da mensagem.
+func contextInfo(msg *waE2E.Message) *waE2E.ContextInfo {
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage().GetContextInfo()
+	case msg.GetLocationMessage() != nil:
+		return msg.GetLocationMessage().GetContextInfo()
+	case msg.GetContactMessage() != nil:
+		return msg.GetContactMessage().GetContextInfo()
+	case pollOf(msg) != nil:
+		return pollOf(msg).GetContextInfo()
+	}
+	return nil
+}
+
+// textOf retorna o texto ou a legenda de uma mensagem citada.
+func textOf(msg *waE2E.Message) string {
+	if msg == nil {
+		return ""
+	}
+	var m models.Message
+	fillContent(&m, msg)
+	return m.Text
+}
+
+// downloadable retorna o anexo da mensagem que pode ser baixado, se houver.
+func downloadable(msg *waE2E.Message) whatsmeow.DownloadableMessage {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage()
+	}
+	return nil
+}
+
+// downloadMedia baixa o anexo recebido para <media.path>/<sessão>/<id da mensagem>.
+func (i *Instancia) downloadMedia(evt *events.Message, m *models.Message) {
+	file := downloadable(evt.Message)
+	if file == nil || m.Media == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mediaDownloadTimeout)
+	defer cancel()
+
+	data, err := i.Client().Download(ctx, file)
+	if err != nil {
+		log.Errorf("Erro ao baixar mídia da mensagem %s: %v", m.ID, err)
+		return
+	}
+
+	path, err := i.SaveMedia(m.ID, Media{Data: data, Mimetype: m.Media.Mimetype, Filename: m.Media.Filename})
+	if err != nil {
+		log.Errorf("Erro ao salvar mídia da mensagem %s: %v", m.ID, err)
+		return
+	}
+
+	m.Media.File = path
+}
+
+// handleMessage normaliza a mensagem recebida, guarda-a no histórico e a
+// entrega aos consumidores. Com media.download, a entrega passa pelo inbox
+// para que o download não segure o handler de eventos.
+func (i *Instancia) handleMessage(evt *events.Message) {
+	m, ok := ConvertMessage(i.Id, evt)
+	if !ok {
+		return
+	}
+
+	if !config.Get().Media.Download {
+		i.deliverMessage(m)
+		return
+	}
+
+	item := inboxItem{evt: evt, msg: m, download: m.Media != nil && downloadable(evt.Message) != nil}
+	if i.inbox.push(item) {
+		go i.deliverMessages()
+	}
+}
+
+// deliverMessage guarda a mensagem no histórico e emite o evento "message".
+func (i *Instancia) deliverMessage(m models.Message) {
+	if database.Available() {
+		i.storeMessage(m)
+	}
+
+	i.emit(models.EventMessage, m)
+}
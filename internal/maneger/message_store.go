@@ -0,0 +1,166 @@
+package maneger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCursor indica um cursor de paginação que não foi gerado por ChatMessages.
+var ErrInvalidCursor = errors.New("cursor inválido")
+
+// MessageQuery são os filtros da consulta ao histórico de uma conversa.
+type MessageQuery struct {
+	Chat   types.JID
+	FromMe *bool     // nil traz enviadas e recebidas
+	Type   string    // tipo da mensagem normalizada (text, image...)
+	Since  time.Time // inclusive; zero não filtra
+	Until  time.Time // exclusive; zero não filtra
+	Search string    // trecho do texto ou da legenda, sem diferenciar maiúsculas
+	Cursor string    // next_cursor da página anterior
+	Limit  int
+}
+
+// ChatMessages lista as mensagens de uma conversa, da mais nova para a mais
+// antiga. O cursor retornado busca a próxima página e é vazio na última.
+func (i *Instancia) ChatMessages(q MessageQuery) ([]models.Message, string, error) {
+	query := database.Instance().
+		Where("instance_id = ? AND chat = ?", i.Id, q.Chat.ToNonAD().String())
+
+	if q.FromMe != nil {
+		query = query.Where("from_me = ?", *q.FromMe)
+	}
+	if q.Type != "" {
+		query = query.Where("type = ?", q.Type)
+	}
+	if !q.Since.IsZero() {
+		query = query.Where("timestamp >= ?", q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		query = query.Where("timestamp < ?", q.Until.UTC())
+	}
+	if q.Search != "" {
+		query = query.Where("LOWER(text) LIKE ?", "%"+strings.ToLower(q.Search)+"%")
+	}
+	if q.Cursor != "" {
+		ts, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("timestamp < ? OR (timestamp = ? AND id < ?)", ts, ts, id)
+	}
+
+	// Uma linha a mais indica se há outra página.
+	var rows []dbmodels.Message
+	err := query.Order("timestamp DESC, id DESC").Limit(q.Limit + 1).Find(&rows).Error
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+		last := rows[len(rows)-1]
+		next = encodeCursor(last.Timestamp, last.ID)
+	}
+
+	list := make([]models.Message, 0, len(rows))
+	for _, row := range rows {
+		var m models.Message
+		if err := json.Unmarshal([]byte(row.Payload), &m); err != nil {
+			log.Errorf("Mensagem %s do histórico está corrompida: %v", row.MessageID, err)
+			continue
+		}
+		list = append(list, m)
+	}
+	return list, next, nil
+}
+
+// storeMessage guarda a mensagem no histórico. Mensagens repetidas (o
+// WhatsApp pode reenviar um evento) são ignoradas.
+func (i *Instancia) storeMessage(m models.Message) {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		log.Errorf("Erro ao serializar mensagem %s: %v", m.ID, err)
+		return
+	}
+
+	err = database.Instance().Clauses(clause.OnConflict{DoNothing: true}).Create(&dbmodels.Message{
+		InstanceID: i.Id,
+		MessageID:  m.ID,
+		Chat:       m.Chat.JID,
+		Sender:     m.Sender.JID,
+		FromMe:     m.FromMe,
+		Type:       string(m.Type),
+		Text:       m.Text,
+		Payload:    string(payload),
+		Timestamp:  m.Timestamp.UTC(),
+	}).Error
+	if err != nil {
+		log.Errorf("Erro ao salvar mensagem %s no histórico: %v", m.ID, err)
+	}
+}
+
+// storeSent guarda no histórico uma mensagem enviada pela API, que o
+// WhatsApp não devolve como evento.
+func (i *Instancia) storeSent(id types.MessageID, to types.JID, msg *waE2E.Message, timestamp time.Time) {
+	var own types.JID
+	if jid := i.Client().Store.ID; jid != nil {
+		own = jid.ToNonAD()
+	}
+
+	m, ok := ConvertMessage(i.Id, &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{
+				Chat:     to.ToNonAD(),
+				Sender:   own,
+				IsFromMe: true,
+				IsGroup:  to.Server == types.GroupServer,
+			},
+			ID:        id,
+			Timestamp: timestamp,
+		},
+		Message: msg,
+	})
+	if ok {
+		i.storeMessage(m)
+	}
+}
+
+// encodeCursor gera o cursor da próxima página a partir da última mensagem.
+func encodeCursor(ts time.Time, id uint) string {
+	raw := fmt.Sprintf("%d.%d", ts.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	n, err1 := strconv.ParseInt(nanos, 10, 64)
+	row, err2 := strconv.ParseUint(id, 10, 64)
+	if err1 != nil || err2 != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	return time.Unix(0, n).UTC(), uint(row), nil
+}
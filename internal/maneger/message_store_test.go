@@ -0,0 +1,134 @@
+package maneger
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// openTestDatabase abre um banco sqlite em memória já migrado.
+func openTestDatabase(t *testing.T) {
+	t.Helper()
+
+	if err := database.Open(database.Config{Driver: "sqlite", DSN: ":memory:"}); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+}
+
+var (
+	historyChat  = types.NewJID("5511999999999", types.DefaultUserServer)
+	historyStart = time.Date(2025, 12, 5, 12, 0, 0, 0, time.UTC)
+)
+
+// storeConversation guarda n mensagens alternando enviadas e recebidas, uma
+// por minuto a partir de historyStart.
+func storeConversation(i *Instancia, n int) {
+	for k := range n {
+		i.storeMessage(models.Message{
+			ID:        fmt.Sprintf("msg-%02d", k),
+			Instance:  i.Id,
+			Type:      models.MessageText,
+			Chat:      models.Chat{JID: historyChat.String(), Number: historyChat.User},
+			FromMe:    k%2 == 0,
+			Timestamp: historyStart.Add(time.Duration(k) * time.Minute),
+			Text:      fmt.Sprintf("Mensagem %d", k),
+		})
+	}
+}
+
+func ids(list []models.Message) []string {
+	out := make([]string, len(list))
+	for n, m := range list {
+		out[n] = m.ID
+	}
+	return out
+}
+
+func TestChatMessagesPagination(t *testing.T) {
+	openTestDatabase(t)
+	i := &Instancia{Id: "teste"}
+	storeConversation(i, 5)
+
+	// Um evento repetido não duplica a mensagem.
+	storeConversation(i, 1)
+
+	var got []string
+	cursor := ""
+	for page := 0; ; page++ {
+		list, next, err := i.ChatMessages(MessageQuery{Chat: historyChat, Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ChatMessages: %v", err)
+		}
+		got = append(got, ids(list)...)
+		if next == "" {
+			break
+		}
+		if page > 5 {
+			t.Fatal("paginação não terminou")
+		}
+		cursor = next
+	}
+
+	want := []string{"msg-04", "msg-03", "msg-02", "msg-01", "msg-00"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("mensagens = %v, esperado %v", got, want)
+	}
+}
+
+func TestChatMessagesFilters(t *testing.T) {
+	openTestDatabase(t)
+	i := &Instancia{Id: "teste"}
+	storeConversation(i, 5)
+
+	fromMe := true
+	tests := []struct {
+		name  string
+		query MessageQuery
+		want  []string
+	}{
+		{"enviadas", MessageQuery{FromMe: &fromMe}, []string{"msg-04", "msg-02", "msg-00"}},
+		{"tipo", MessageQuery{Type: string(models.MessageImage)}, []string{}},
+		{"período", MessageQuery{Since: historyStart.Add(time.Minute), Until: historyStart.Add(3 * time.Minute)}, []string{"msg-02", "msg-01"}},
+		{"texto", MessageQuery{Search: "MENSAGEM 3"}, []string{"msg-03"}},
+		{"outra conversa", MessageQuery{Chat: types.NewJID("123", types.GroupServer)}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			if q.Chat.IsEmpty() {
+				q.Chat = historyChat
+			}
+			q.Limit = 10
+
+			list, next, err := i.ChatMessages(q)
+			if err != nil {
+				t.Fatalf("ChatMessages: %v", err)
+			}
+			if got := ids(list); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("mensagens = %v, esperado %v", got, tt.want)
+			}
+			if next != "" {
+				t.Fatalf("next_cursor = %q na última página", next)
+			}
+		})
+	}
+}
+
+func TestChatMessagesInvalidCursor(t *testing.T) {
+	openTestDatabase(t)
+	i := &Instancia{Id: "teste"}
+
+	if _, _, err := i.ChatMessages(MessageQuery{Chat: historyChat, Limit: 10, Cursor: "???"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("ChatMessages = %v, esperado %v", err, ErrInvalidCursor)
+	}
+}
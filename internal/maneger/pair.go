@@ -0,0 +1,78 @@
+package maneger
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow"
+)
+
+var ErrAlreadyPaired = errors.New("sessão já está pareada")
+
+// pairClientDisplay precisa seguir o formato "Navegador (SO)" e bater com store.DeviceProps.
+const pairClientDisplay = "Chrome (Windows)"
+
+// PairPhone inicia a conexão como Start e gera o código de 8 caracteres para
+// parear pelo número de telefone, sem ler o QR code. O fim do fluxo é
+// informado pelos mesmos eventos do QR (success, timeout ou error).
+func (i *Instancia) PairPhone(ctx context.Context, phone string) (string, error) {
+	if i.Client().Store.ID != nil {
+		return "", ErrAlreadyPaired
+	}
+
+	if i.Stopped.Load() {
+		if err := i.Start(); err != nil {
+			return "", err
+		}
+	} else if !i.Client().IsConnected() {
+		// Reaproveita o fluxo em andamento, se houver.
+		i.startQRFlow()
+	}
+
+	// O whatsmeow exige que o primeiro QR tenha chegado antes de pedir o código.
+	if err := i.waitQR(ctx); err != nil {
+		return "", err
+	}
+
+	code, err := i.Client().PairPhone(ctx, phone, true, whatsmeow.PairClientChrome, pairClientDisplay)
+	if err != nil {
+		return "", err
+	}
+
+	i.Mu.Lock()
+	i.LastPairCode = code
+	i.LastPairCodeTime = time.Now()
+	i.Mu.Unlock()
+
+	i.setState(models.StatePairing, "código de pareamento gerado")
+	i.emit(models.EventQR, &QRCodeEvent{Event: QREventPairCode, Code: code})
+
+	return code, nil
+}
+
+// waitQR aguarda o fluxo de login gerar o primeiro QR code.
+func (i *Instancia) waitQR(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		i.Mu.RLock()
+		ready := i.LastQR != nil
+		i.Mu.RUnlock()
+
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("tempo esgotado aguardando a conexão com o WhatsApp")
+		case <-ticker.C:
+		}
+	}
+}
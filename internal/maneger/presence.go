@@ -0,0 +1,54 @@
+package maneger
+
+import (
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ConvertReceipt converte uma confirmação do whatsmeow para o formato normalizado.
+func ConvertReceipt(evt *events.Receipt) models.Receipt {
+	kind := string(evt.Type)
+	if evt.Type == types.ReceiptTypeDelivered {
+		kind = "delivered"
+	}
+
+	return models.Receipt{
+		Chat:       evt.Chat.String(),
+		Sender:     evt.Sender.ToNonAD().String(),
+		MessageIDs: evt.MessageIDs,
+		Type:       kind,
+		Timestamp:  evt.Timestamp,
+	}
+}
+
+// ConvertPresence converte uma atualização de presença (online/offline).
+func ConvertPresence(evt *events.Presence) models.Presence {
+	p := models.Presence{
+		From:  evt.From.ToNonAD().String(),
+		State: string(types.PresenceAvailable),
+	}
+
+	if evt.Unavailable {
+		p.State = string(types.PresenceUnavailable)
+	}
+	if !evt.LastSeen.IsZero() {
+		p.LastSeen = &evt.LastSeen
+	}
+
+	return p
+}
+
+// ConvertChatPresence converte um aviso de digitação/gravação em uma conversa.
+func ConvertChatPresence(evt *events.ChatPresence) models.Presence {
+	state := string(evt.State)
+	if evt.State == types.ChatPresenceComposing && evt.Media == types.ChatPresenceMediaAudio {
+		state = "recording"
+	}
+
+	return models.Presence{
+		From:  evt.Sender.ToNonAD().String(),
+		Chat:  evt.Chat.String(),
+		State: state,
+	}
+}
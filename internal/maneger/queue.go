@@ -0,0 +1,620 @@
+package maneger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/models"
+	"github.com/gedsonn/zaapi/internal/ratelimit"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Priority é a faixa da fila. Mensagens de faixas mais altas saem antes.
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+)
+
+var priorityNames = map[string]Priority{
+	"high":   PriorityHigh,
+	"normal": PriorityNormal,
+	"low":    PriorityLow,
+}
+
+// ParsePriority converte "high", "normal" ou "low". Vazio é normal.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	p, ok := priorityNames[s]
+	if !ok {
+		return 0, fmt.Errorf("prioridade inválida: %s", s)
+	}
+	return p, nil
+}
+
+func (p Priority) String() string {
+	for name, v := range priorityNames {
+		if v == p {
+			return name
+		}
+	}
+	return "normal"
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	v, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// keepFinished é quantas mensagens finalizadas ficam em memória para consulta
+// quando não há banco de dados.
+const keepFinished = 1000
+
+// notLoggedInPoll é o intervalo de verificação enquanto a sessão está desconectada.
+const notLoggedInPoll = 2 * time.Second
+
+// Mensagens finalizadas ficam no banco por queue.retention dias
+// (defaultRetention se zero) e são apagadas a cada pruneInterval.
+const (
+	defaultRetention = 7
+	pruneInterval    = time.Hour
+)
+
+// errInterrupted é o erro das mensagens cujo envio foi interrompido depois de
+// começar. Elas não são reenviadas, porque podem ter chegado ao destinatário.
+const errInterrupted = "envio interrompido; a mensagem pode ter sido entregue"
+
+// defaultMaxPending é o limite de mensagens aguardando envio quando
+// queue.max_pending não está definido.
+const defaultMaxPending = 1000
+
+// ErrQueueFull indica que a fila da sessão atingiu queue.max_pending.
+var ErrQueueFull = errors.New("a fila de envio da sessão está cheia")
+
+// QueueFullError é o ErrQueueFull retornado pelo Enqueue, com a espera
+// estimada até a próxima mensagem sair da fila.
+type QueueFullError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QueueFullError) Error() string { return ErrQueueFull.Error() }
+func (e *QueueFullError) Unwrap() error { return ErrQueueFull }
+
+// Seconds é RetryAfter arredondado para cima, como no header Retry-After.
+func (e *QueueFullError) Seconds() int {
+	return (&ratelimit.Error{RetryAfter: e.RetryAfter}).Seconds()
+}
+
+// QueueOptions são as opções de uma mensagem enfileirada.
+type QueueOptions struct {
+	Priority Priority
+	Typing   *bool // nil usa queue.typing do config.yml
+}
+
+// QueueItem é uma mensagem na fila de envio. O ID é o id da mensagem no WhatsApp.
+type QueueItem struct {
+	ID        string     `json:"id"`
+	Instance  string     `json:"instance"`
+	To        string     `json:"to"`
+	Priority  Priority   `json:"priority"`
+	Typing    bool       `json:"typing"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+
+	msg *waE2E.Message
+}
+
+// sendQueue guarda as mensagens pendentes por faixa de prioridade.
+type sendQueue struct {
+	mu       sync.Mutex
+	lanes    [PriorityLow + 1][]*QueueItem
+	items    map[string]*QueueItem
+	finished []string
+	wake     chan struct{}
+
+	// current é o item que o worker retirou da fila e ainda não entregou ao
+	// WhatsApp (na pausa, aguardando o limite ou "digitando..."). Enquanto
+	// cancel não é nil ele ainda pode ser cancelado; done fecha quando o
+	// worker termina de tratá-lo.
+	current  *QueueItem
+	cancel   context.CancelFunc
+	canceled bool
+	done     chan struct{}
+}
+
+func newSendQueue() *sendQueue {
+	return &sendQueue{
+		items: make(map[string]*QueueItem),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// push coloca o item no fim (ou no início) da sua faixa, acorda o worker e
+// retorna uma cópia do item tirada antes de o worker alcançá-lo.
+func (q *sendQueue) push(item *QueueItem, front bool) QueueItem {
+	q.mu.Lock()
+	lane := &q.lanes[item.Priority]
+	if front {
+		*lane = append([]*QueueItem{item}, *lane...)
+	} else {
+		*lane = append(*lane, item)
+	}
+	q.items[item.ID] = item
+	snapshot := *item
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return snapshot
+}
+
+// size retorna quantas mensagens aguardam envio, incluindo a que o worker
+// está preparando.
+func (q *sendQueue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, lane := range q.lanes {
+		n += len(lane)
+	}
+	if q.current != nil {
+		n++
+	}
+	return n
+}
+
+// pop retira o próximo item, da faixa mais prioritária para a menos.
+func (q *sendQueue) pop() *QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for p := range q.lanes {
+		if len(q.lanes[p]) > 0 {
+			item := q.lanes[p][0]
+			q.lanes[p] = q.lanes[p][1:]
+			return item
+		}
+	}
+	return nil
+}
+
+// begin marca item como o que o worker está preparando; cancel interrompe a
+// preparação.
+func (q *sendQueue) begin(item *QueueItem, cancel context.CancelFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.current = item
+	q.cancel = cancel
+	q.canceled = false
+	q.done = make(chan struct{})
+}
+
+// commit é chamado logo antes de entregar o item ao WhatsApp. Retorna false
+// se ele foi cancelado; depois do commit o item não pode mais ser cancelado.
+func (q *sendQueue) commit() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.canceled {
+		return false
+	}
+	q.cancel = nil
+	return true
+}
+
+// end libera o item atual e informa se ele foi cancelado. O worker fecha
+// done depois de registrar o resultado.
+func (q *sendQueue) end() (canceled bool, done chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	canceled, done = q.canceled, q.done
+	q.current, q.cancel, q.canceled, q.done = nil, nil, false, nil
+	return canceled, done
+}
+
+// cancelCurrent cancela o item que o worker está preparando, se for id e o
+// envio ainda não começou. O canal fecha quando o cancelamento foi registrado.
+func (q *sendQueue) cancelCurrent(id string) (<-chan struct{}, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.current == nil || q.current.ID != id || q.cancel == nil {
+		return nil, false
+	}
+	q.canceled = true
+	q.cancel()
+	return q.done, true
+}
+
+// remove tira da fila um item ainda não enviado.
+func (q *sendQueue) remove(id string) (*QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for p, lane := range q.lanes {
+		for n, item := range lane {
+			if item.ID == id {
+				q.lanes[p] = append(lane[:n:n], lane[n+1:]...)
+				return item, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// finish registra que o item terminou, descartando os finalizados mais antigos.
+func (q *sendQueue) finish(item *QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.finished = append(q.finished, item.ID)
+	if len(q.finished) > keepFinished {
+		delete(q.items, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
+
+// get retorna uma cópia do item.
+func (q *sendQueue) get(id string) (QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[id]
+	if !ok {
+		return QueueItem{}, false
+	}
+	return *item, true
+}
+
+// pending retorna cópias dos itens aguardando envio, na ordem de saída,
+// começando pelo que o worker está preparando.
+func (q *sendQueue) pending() []QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	list := []QueueItem{}
+	if q.current != nil {
+		list = append(list, *q.current)
+	}
+	for _, lane := range q.lanes {
+		for _, item := range lane {
+			list = append(list, *item)
+		}
+	}
+	return list
+}
+
+func (q *sendQueue) setStatus(item *QueueItem, status, errMsg string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item.Status = status
+	item.Error = errMsg
+	if status == dbmodels.QueueSent {
+		now := time.Now()
+		item.SentAt = &now
+	}
+}
+
+// Enqueue coloca a mensagem na fila de envio e retorna imediatamente. O
+// andamento é informado pelo evento "queue". Com queue.max_pending mensagens
+// aguardando, retorna um *QueueFullError.
+func (i *Instancia) Enqueue(to types.JID, msg *waE2E.Message, opts QueueOptions) (QueueItem, error) {
+	cfg := config.Get().Queue
+
+	limit := cfg.MaxPending
+	if limit <= 0 {
+		limit = defaultMaxPending
+	}
+	if i.queue.size() >= limit {
+		return QueueItem{}, &QueueFullError{RetryAfter: i.nextSendIn(cfg)}
+	}
+
+	typing := cfg.Typing
+	if opts.Typing != nil {
+		typing = *opts.Typing
+	}
+
+	item := &QueueItem{
+		ID:        string(i.Client().GenerateMessageID()),
+		Instance:  i.Id,
+		To:        to.String(),
+		Priority:  opts.Priority,
+		Typing:    typing,
+		Status:    dbmodels.QueueQueued,
+		CreatedAt: time.Now(),
+		msg:       msg,
+	}
+
+	if database.Available() {
+		if err := saveQueued(item); err != nil {
+			return QueueItem{}, err
+		}
+	}
+
+	snapshot := i.queue.push(item, false)
+	i.emit(models.EventQueue, snapshot)
+
+	return snapshot, nil
+}
+
+// Queued retorna uma mensagem da fila pelo id, inclusive as já enviadas.
+func (i *Instancia) Queued(id string) (QueueItem, bool) {
+	if item, ok := i.queue.get(id); ok {
+		return item, true
+	}
+	if database.Available() {
+		return getQueued(i.Id, id)
+	}
+	return QueueItem{}, false
+}
+
+// PendingQueue lista as mensagens aguardando envio, na ordem de saída.
+func (i *Instancia) PendingQueue() []QueueItem {
+	return i.queue.pending()
+}
+
+// CancelQueued cancela uma mensagem que ainda não foi enviada, inclusive a
+// que o worker já retirou da fila e está na pausa ou "digitando...".
+func (i *Instancia) CancelQueued(id string) bool {
+	if item, ok := i.queue.remove(id); ok {
+		i.finishQueued(item, dbmodels.QueueCanceled, "")
+		return true
+	}
+
+	// O worker registra o cancelamento; espera para que a situação já
+	// apareça como canceled na resposta.
+	done, ok := i.queue.cancelCurrent(id)
+	if ok {
+		<-done
+	}
+	return ok
+}
+
+// runQueue envia as mensagens da fila uma a uma enquanto a instância está
+// em execução, com pausa aleatória e "digitando..." antes de cada envio.
+func (i *Instancia) runQueue(ctx context.Context) {
+	var pruned time.Time
+	for {
+		if !i.Client().IsLoggedIn() {
+			if !sleep(ctx, notLoggedInPoll) {
+				return
+			}
+			continue
+		}
+
+		item := i.queue.pop()
+		if item == nil {
+			// Aproveita a fila vazia para limpar o banco.
+			if database.Available() && time.Since(pruned) >= pruneInterval {
+				i.pruneQueue()
+				pruned = time.Now()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-i.queue.wake:
+			case <-time.After(notLoggedInPoll):
+			}
+			continue
+		}
+
+		if !i.processQueued(ctx, item) {
+			return
+		}
+	}
+}
+
+// processQueued trata um item retirado da fila. Retorna false se o contexto
+// foi cancelado pelo Stop; nesse caso o item volta para o início da fila.
+func (i *Instancia) processQueued(ctx context.Context, item *QueueItem) bool {
+	itemCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	i.queue.begin(item, cancel)
+	sent := i.sendQueued(itemCtx, item)
+
+	canceled, done := i.queue.end()
+	defer close(done)
+
+	switch {
+	case canceled:
+		i.finishQueued(item, dbmodels.QueueCanceled, "")
+	case !sent:
+		i.queue.setStatus(item, dbmodels.QueueQueued, "")
+		i.queue.push(item, true)
+		return false
+	}
+	return true
+}
+
+// sendQueued envia um item. Retorna false se o contexto foi cancelado antes
+// do envio, pelo Stop ou pelo CancelQueued.
+func (i *Instancia) sendQueued(ctx context.Context, item *QueueItem) bool {
+	cfg := config.Get().Queue
+
+	if !sleep(ctx, jitter(cfg)) {
+		return false
+	}
+
+	// Aguarda o limite de envio liberar em vez de falhar a mensagem.
+	for {
+		err := i.CheckRate()
+		var limited *ratelimit.Error
+		if !errors.As(err, &limited) {
+			break
+		}
+		if !sleep(ctx, limited.RetryAfter) {
+			return false
+		}
+	}
+
+	to, err := ParseRecipient(item.To)
+	if err != nil {
+		if !i.queue.commit() {
+			return false
+		}
+		i.finishQueued(item, dbmodels.QueueFailed, err.Error())
+		return true
+	}
+
+	if item.Typing {
+		if !i.simulateTyping(ctx, to, item.msg, cfg) {
+			return false
+		}
+	}
+
+	if !i.queue.commit() {
+		return false
+	}
+
+	// Daqui em diante a mensagem pode chegar ao WhatsApp: uma linha que fica
+	// em sending (queda do processo no meio do envio) não é enviada de novo.
+	i.queue.setStatus(item, dbmodels.QueueSending, "")
+	if database.Available() {
+		updateQueued(item)
+	}
+
+	_, err = i.Send(ctx, to, item.msg, whatsmeow.SendRequestExtra{ID: types.MessageID(item.ID)})
+
+	var limited *ratelimit.Error
+	switch {
+	case err == nil:
+		i.finishQueued(item, dbmodels.QueueSent, "")
+	case ctx.Err() != nil:
+		i.finishQueued(item, dbmodels.QueueFailed, errInterrupted)
+	case errors.Is(err, ErrNotLoggedIn), errors.As(err, &limited):
+		// Falha temporária, recusada antes de sair: tenta de novo quando possível.
+		i.queue.setStatus(item, dbmodels.QueueQueued, "")
+		if database.Available() {
+			updateQueued(item)
+		}
+		i.queue.push(item, true)
+	default:
+		log.Errorf("Erro ao enviar mensagem %s da fila da instância %s: %v", item.ID, i.Id, err)
+		i.finishQueued(item, dbmodels.QueueFailed, err.Error())
+	}
+
+	return true
+}
+
+// pruneQueue apaga do banco as mensagens finalizadas há mais de
+// queue.retention dias.
+func (i *Instancia) pruneQueue() {
+	days := config.Get().Queue.Retention
+	if days <= 0 {
+		days = defaultRetention
+	}
+
+	n, err := pruneQueued(i.Id, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Errorf("Erro ao limpar fila de envio da instância %s: %v", i.Id, err)
+		return
+	}
+	if n > 0 {
+		log.Infof("Instância %s: %d mensagens finalizadas removidas da fila", i.Id, n)
+	}
+}
+
+// simulateTyping envia "digitando..." por um tempo proporcional ao texto.
+func (i *Instancia) simulateTyping(ctx context.Context, to types.JID, msg *waE2E.Message, cfg config.QueueConfig) bool {
+	speed := max(cfg.TypingSpeed, 1)
+	duration := time.Duration(utf8.RuneCountInString(textOf(msg))) * time.Second / time.Duration(speed)
+	duration = min(max(duration, time.Second), time.Duration(max(cfg.MaxTyping, 1000))*time.Millisecond)
+
+	if err := i.Client().SendChatPresence(ctx, to, types.ChatPresenceComposing, types.ChatPresenceMediaText); err != nil {
+		log.Warnf("Erro ao enviar presença digitando para %s: %v", to, err)
+	}
+
+	ok := sleep(ctx, duration)
+
+	if err := i.Client().SendChatPresence(context.Background(), to, types.ChatPresencePaused, types.ChatPresenceMediaText); err != nil {
+		log.Warnf("Erro ao encerrar presença digitando para %s: %v", to, err)
+	}
+
+	return ok
+}
+
+// finishQueued registra o resultado final do item e emite o evento "queue".
+func (i *Instancia) finishQueued(item *QueueItem, status, errMsg string) {
+	i.queue.setStatus(item, status, errMsg)
+	i.queue.finish(item)
+
+	if database.Available() {
+		updateQueued(item)
+		campaignQueued(item)
+	}
+
+	snapshot, _ := i.queue.get(item.ID)
+	i.emit(models.EventQueue, snapshot)
+}
+
+// nextSendIn estima quando a fila envia a próxima mensagem: a pausa média
+// entre mensagens ou, se maior, a espera pelo limite de envio.
+func (i *Instancia) nextSendIn(cfg config.QueueConfig) time.Duration {
+	wait := time.Duration(max(cfg.MinDelay, 0)+max(cfg.MaxDelay, 0)) * time.Millisecond / 2
+
+	var limited *ratelimit.Error
+	if errors.As(i.CheckRate(), &limited) {
+		wait = max(wait, limited.RetryAfter)
+	}
+	return wait
+}
+
+// jitter sorteia a pausa antes da próxima mensagem entre min_delay e max_delay.
+func jitter(cfg config.QueueConfig) time.Duration {
+	lo, hi := max(cfg.MinDelay, 0), max(cfg.MaxDelay, 0)
+	if hi <= lo {
+		return time.Duration(lo) * time.Millisecond
+	}
+	return time.Duration(lo+rand.IntN(hi-lo+1)) * time.Millisecond
+}
+
+// sleep aguarda d ou o cancelamento do contexto. Retorna false se foi cancelado.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,117 @@
+package maneger
+
+import (
+	"errors"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+)
+
+// saveQueued grava a mensagem enfileirada para que sobreviva a reinícios.
+func saveQueued(item *QueueItem) error {
+	payload, err := proto.Marshal(item.msg)
+	if err != nil {
+		return err
+	}
+
+	return database.Instance().Create(&dbmodels.QueuedMessage{
+		ID:         item.ID,
+		InstanceID: item.Instance,
+		To:         item.To,
+		Priority:   int(item.Priority),
+		Typing:     item.Typing,
+		Payload:    payload,
+		Status:     item.Status,
+		CreatedAt:  item.CreatedAt,
+	}).Error
+}
+
+func updateQueued(item *QueueItem) {
+	err := database.Instance().Model(&dbmodels.QueuedMessage{}).
+		Where("id = ?", item.ID).
+		Updates(map[string]any{
+			"status":  item.Status,
+			"error":   item.Error,
+			"sent_at": item.SentAt,
+		}).Error
+	if err != nil {
+		log.Errorf("Erro ao atualizar mensagem %s da fila: %v", item.ID, err)
+	}
+}
+
+func getQueued(instance, id string) (QueueItem, bool) {
+	var row dbmodels.QueuedMessage
+	err := database.Instance().Where("id = ? AND instance_id = ?", id, instance).First(&row).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Errorf("Erro ao buscar mensagem %s da fila: %v", id, err)
+		}
+		return QueueItem{}, false
+	}
+
+	item, _ := queueItemFromRow(row)
+	return item, true
+}
+
+// loadQueue devolve à fila as mensagens que não foram enviadas na execução
+// anterior. As que ficaram em sending podem ter sido entregues antes da queda
+// e são marcadas como failed em vez de reenviadas.
+func (i *Instancia) loadQueue() {
+	db := database.Instance()
+
+	err := db.Model(&dbmodels.QueuedMessage{}).
+		Where("instance_id = ? AND status = ?", i.Id, dbmodels.QueueSending).
+		Updates(map[string]any{"status": dbmodels.QueueFailed, "error": errInterrupted}).Error
+	if err != nil {
+		log.Errorf("Erro ao marcar envios interrompidos da instância %s: %v", i.Id, err)
+	}
+
+	var rows []dbmodels.QueuedMessage
+	err = db.Where("instance_id = ? AND status = ?", i.Id, dbmodels.QueueQueued).
+		Order("created_at").
+		Find(&rows).Error
+	if err != nil {
+		log.Errorf("Erro ao carregar fila de envio da instância %s: %v", i.Id, err)
+		return
+	}
+
+	for _, row := range rows {
+		item, err := queueItemFromRow(row)
+		if err != nil {
+			log.Errorf("Mensagem %s da fila está corrompida: %v", row.ID, err)
+			continue
+		}
+		i.queue.push(&item, false)
+	}
+}
+
+// pruneQueued apaga as mensagens da instância finalizadas antes de before.
+func pruneQueued(instance string, before time.Time) (int64, error) {
+	res := database.Instance().
+		Where("instance_id = ? AND status IN ? AND updated_at < ?", instance,
+			[]string{dbmodels.QueueSent, dbmodels.QueueFailed, dbmodels.QueueCanceled}, before).
+		Delete(&dbmodels.QueuedMessage{})
+	return res.RowsAffected, res.Error
+}
+
+func queueItemFromRow(row dbmodels.QueuedMessage) (QueueItem, error) {
+	item := QueueItem{
+		ID:        row.ID,
+		Instance:  row.InstanceID,
+		To:        row.To,
+		Priority:  Priority(row.Priority),
+		Typing:    row.Typing,
+		Status:    row.Status,
+		Error:     row.Error,
+		CreatedAt: row.CreatedAt,
+		SentAt:    row.SentAt,
+		msg:       &waE2E.Message{},
+	}
+
+	return item, proto.Unmarshal(row.Payload, item.msg)
+}
@@ -0,0 +1,81 @@
+package maneger
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+)
+
+// TestLoadQueueFailsInterruptedSends garante que uma mensagem que ficou em
+// sending numa queda não é reenviada.
+func TestLoadQueueFailsInterruptedSends(t *testing.T) {
+	openTestDatabase(t)
+
+	created := time.Now().Add(-time.Hour)
+	for _, item := range []*QueueItem{
+		{ID: "na-fila", Status: dbmodels.QueueQueued},
+		{ID: "enviando", Status: dbmodels.QueueSending},
+	} {
+		item.Instance, item.To, item.CreatedAt = "teste", testRecipient.String(), created
+		item.msg = BuildText("oi", SendOptions{})
+		if err := saveQueued(item); err != nil {
+			t.Fatalf("saveQueued: %v", err)
+		}
+	}
+
+	i := &Instancia{Id: "teste", queue: newSendQueue()}
+	i.loadQueue()
+
+	if got := queueIDs(i.PendingQueue()); got != "[na-fila]" {
+		t.Fatalf("fila = %s, esperado [na-fila]", got)
+	}
+
+	var row dbmodels.QueuedMessage
+	database.Instance().First(&row, "id = ?", "enviando")
+	if row.Status != dbmodels.QueueFailed || row.Error != errInterrupted {
+		t.Fatalf("envio interrompido ficou %s (%q), esperado %s", row.Status, row.Error, dbmodels.QueueFailed)
+	}
+	if !row.UpdatedAt.After(created) {
+		t.Fatal("updated_at não foi atualizado")
+	}
+}
+
+func TestPruneQueued(t *testing.T) {
+	openTestDatabase(t)
+
+	old := time.Now().AddDate(0, 0, -10)
+	for _, row := range []dbmodels.QueuedMessage{
+		{ID: "enviada-antiga", Status: dbmodels.QueueSent, UpdatedAt: old},
+		{ID: "falha-antiga", Status: dbmodels.QueueFailed, UpdatedAt: old},
+		{ID: "enviada-recente", Status: dbmodels.QueueSent},
+		{ID: "na-fila-antiga", Status: dbmodels.QueueQueued, UpdatedAt: old},
+	} {
+		row.InstanceID = "teste"
+		if err := database.Instance().Create(&row).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	n, err := pruneQueued("teste", time.Now().AddDate(0, 0, -defaultRetention))
+	if err != nil || n != 2 {
+		t.Fatalf("pruneQueued = %d (%v), esperado 2", n, err)
+	}
+
+	var left []string
+	database.Instance().Model(&dbmodels.QueuedMessage{}).Order("id").Pluck("id", &left)
+	if fmt.Sprint(left) != "[enviada-recente na-fila-antiga]" {
+		t.Fatalf("restaram %v", left)
+	}
+}
+
+// queueIDs lista os ids dos itens, na ordem.
+func queueIDs(list []QueueItem) string {
+	out := make([]string, len(list))
+	for n, item := range list {
+		out[n] = item.ID
+	}
+	return fmt.Sprint(out)
+}
@@ -0,0 +1,187 @@
+package maneger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"go.mau.fi/whatsmeow/types"
+)
+
+var testRecipient = types.NewJID("5511999999999", types.DefaultUserServer)
+
+// withQueueConfig aplica as opções da fila apenas durante o teste.
+func withQueueConfig(t *testing.T, queue config.QueueConfig) {
+	t.Helper()
+
+	prev := config.Get()
+	cfg := config.DefaultConfig()
+	cfg.Queue = queue
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(prev) })
+}
+
+func enqueue(t *testing.T, i *Instancia, opts QueueOptions) QueueItem {
+	t.Helper()
+
+	item, err := i.Enqueue(testRecipient, BuildText("oi", SendOptions{}), opts)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return item
+}
+
+func TestQueuePriorityOrder(t *testing.T) {
+	q := newSendQueue()
+	for _, item := range []*QueueItem{
+		{ID: "low", Priority: PriorityLow},
+		{ID: "normal-1", Priority: PriorityNormal},
+		{ID: "high", Priority: PriorityHigh},
+		{ID: "normal-2", Priority: PriorityNormal},
+	} {
+		q.push(item, false)
+	}
+	q.push(&QueueItem{ID: "retry", Priority: PriorityNormal}, true)
+
+	want := []string{"high", "retry", "normal-1", "normal-2", "low"}
+
+	pending := q.pending()
+	for n, id := range want {
+		if pending[n].ID != id {
+			t.Fatalf("pending[%d] = %s, esperado %s", n, pending[n].ID, id)
+		}
+	}
+
+	for _, id := range want {
+		if item := q.pop(); item == nil || item.ID != id {
+			t.Fatalf("pop = %+v, esperado %s", item, id)
+		}
+	}
+	if item := q.pop(); item != nil {
+		t.Fatalf("fila vazia retornou %s", item.ID)
+	}
+}
+
+func TestEnqueueQueueFull(t *testing.T) {
+	withQueueConfig(t, config.QueueConfig{MaxPending: 2, MinDelay: 2000, MaxDelay: 4000})
+	i := newTestInstance(t)
+
+	enqueue(t, i, QueueOptions{})
+	enqueue(t, i, QueueOptions{})
+
+	_, err := i.Enqueue(testRecipient, BuildText("oi", SendOptions{}), QueueOptions{})
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Enqueue com a fila cheia = %v, esperado %v", err, ErrQueueFull)
+	}
+	// Retry-After é a pausa média entre mensagens.
+	var full *QueueFullError
+	if !errors.As(err, &full) || full.Seconds() != 3 {
+		t.Fatalf("Enqueue com a fila cheia = %#v, esperado Retry-After de 3s", err)
+	}
+
+	// A mensagem em preparação pelo worker também ocupa a fila.
+	item := i.queue.pop()
+	i.queue.begin(item, func() {})
+	if _, err := i.Enqueue(testRecipient, BuildText("oi", SendOptions{}), QueueOptions{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Enqueue com um item em preparação = %v, esperado %v", err, ErrQueueFull)
+	}
+}
+
+func TestCancelQueuedPending(t *testing.T) {
+	i := newTestInstance(t)
+
+	item := enqueue(t, i, QueueOptions{})
+	if item.Status != dbmodels.QueueQueued {
+		t.Fatalf("status = %s, esperado %s", item.Status, dbmodels.QueueQueued)
+	}
+
+	if !i.CancelQueued(item.ID) {
+		t.Fatal("CancelQueued = false para uma mensagem na fila")
+	}
+	if got, _ := i.Queued(item.ID); got.Status != dbmodels.QueueCanceled {
+		t.Fatalf("status = %s, esperado %s", got.Status, dbmodels.QueueCanceled)
+	}
+	if n := len(i.PendingQueue()); n != 0 {
+		t.Fatalf("%d mensagens pendentes após cancelar", n)
+	}
+	if i.CancelQueued(item.ID) {
+		t.Fatal("CancelQueued = true para uma mensagem já cancelada")
+	}
+}
+
+// TestCancelQueuedInFlight cancela a mensagem durante a pausa anterior ao
+// envio, depois que o worker já a retirou da fila.
+func TestCancelQueuedInFlight(t *testing.T) {
+	withQueueConfig(t, config.QueueConfig{MinDelay: 60000, MaxDelay: 60000})
+	i := newTestInstance(t)
+
+	queued := enqueue(t, i, QueueOptions{})
+	item := i.queue.pop()
+
+	result := make(chan bool, 1)
+	go func() { result <- i.processQueued(context.Background(), item) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !i.CancelQueued(queued.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("CancelQueued = false para a mensagem em preparação")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got, _ := i.Queued(queued.ID); got.Status != dbmodels.QueueCanceled {
+		t.Fatalf("status = %s, esperado %s", got.Status, dbmodels.QueueCanceled)
+	}
+
+	select {
+	case ok := <-result:
+		if !ok {
+			t.Fatal("processQueued = false: o worker pararia após um cancelamento")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("processQueued não terminou após o cancelamento")
+	}
+
+	if n := len(i.PendingQueue()); n != 0 {
+		t.Fatalf("%d mensagens pendentes após cancelar", n)
+	}
+}
+
+func TestCancelAfterCommit(t *testing.T) {
+	q := newSendQueue()
+	item := &QueueItem{ID: "msg"}
+
+	q.begin(item, func() {})
+	if !q.commit() {
+		t.Fatal("commit = false sem cancelamento")
+	}
+	if _, ok := q.cancelCurrent(item.ID); ok {
+		t.Fatal("cancelCurrent = true depois de o envio começar")
+	}
+}
+
+// TestStopRequeuesInFlight garante que uma mensagem interrompida pelo Stop
+// volta para o início da fila.
+func TestStopRequeuesInFlight(t *testing.T) {
+	withQueueConfig(t, config.QueueConfig{MinDelay: 60000, MaxDelay: 60000})
+	i := newTestInstance(t)
+
+	first := enqueue(t, i, QueueOptions{})
+	enqueue(t, i, QueueOptions{})
+	item := i.queue.pop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if i.processQueued(ctx, item) {
+		t.Fatal("processQueued = true com o contexto cancelado")
+	}
+
+	pending := i.PendingQueue()
+	if len(pending) != 2 || pending[0].ID != first.ID || pending[0].Status != dbmodels.QueueQueued {
+		t.Fatalf("fila após o Stop = %+v", pending)
+	}
+}
@@ -0,0 +1,53 @@
+package maneger
+
+import (
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/ratelimit"
+)
+
+// Limits retorna os limites de envio em vigor: os da instância ou, sem
+// sobrescrita, os de ratelimit.instance do config.yml. override informa se
+// a instância tem limites próprios.
+func (i *Instancia) Limits() (limits config.Limits, override bool) {
+	i.Mu.RLock()
+	defer i.Mu.RUnlock()
+
+	if i.RateLimit != nil {
+		return *i.RateLimit, true
+	}
+	return config.Get().RateLimit.Instance, false
+}
+
+// SetRateLimit sobrescreve os limites de envio da instância. nil volta a usar
+// os limites globais. Os contadores recomeçam com os novos limites.
+func (i *Instancia) SetRateLimit(l *config.Limits) error {
+	i.Mu.Lock()
+	defer i.Mu.Unlock()
+
+	i.RateLimit = l
+	i.limiter = nil
+	i.limiterReady = false
+
+	return i.save()
+}
+
+// CheckRate informa se a instância pode enviar agora, sem consumir o envio.
+func (i *Instancia) CheckRate() error {
+	return i.rateLimiter().Check()
+}
+
+// rateLimiter retorna o limiter da instância, criando-o no primeiro uso.
+func (i *Instancia) rateLimiter() *ratelimit.Limiter {
+	i.Mu.Lock()
+	defer i.Mu.Unlock()
+
+	if !i.limiterReady {
+		limits := config.Get().RateLimit.Instance
+		if i.RateLimit != nil {
+			limits = *i.RateLimit
+		}
+		i.limiter = ratelimit.New(limits)
+		i.limiterReady = true
+	}
+	return i.limiter
+}
@@ -0,0 +1,464 @@
+package maneger
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/models"
+	"github.com/goccy/go-yaml"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrInvalidSchedule = errors.New("informe send_at no futuro ou uma expressão cron")
+	ErrInvalidCron     = errors.New("expressão cron ou fuso horário inválido")
+)
+
+// scheduleUploadTimeout limita o upload da mídia de um agendamento no disparo.
+const scheduleUploadTimeout = 2 * time.Minute
+
+// Schedule é uma mensagem agendada: envio único em SendAt ou recorrente pela
+// expressão Cron, no fuso Timezone. Persistido em sessions/<id>/schedules.yml.
+type Schedule struct {
+	ID        string          `json:"id"`
+	To        string          `json:"to"`
+	SendAt    *time.Time      `json:"send_at,omitempty"`
+	Cron      string          `json:"cron,omitempty"`
+	Timezone  string          `json:"timezone,omitempty"`
+	Priority  Priority        `json:"priority"`
+	Typing    *bool           `json:"typing,omitempty"`
+	Payload   string          `json:"-" yaml:"payload,omitempty"` // waE2E.Message em protobuf, base64
+	Media     *ScheduledMedia `json:"media,omitempty" yaml:"media,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	LastRun   *time.Time      `json:"last_run,omitempty"`
+	Runs      int             `json:"runs"`
+	NextRun   *time.Time      `json:"next_run,omitempty" yaml:"-"`
+}
+
+// ScheduledMedia é a mídia de um agendamento. O arquivo fica em
+// sessions/<id>/schedules/<id do agendamento> e só é enviado ao WhatsApp no
+// disparo: o upload expira e não serviria para envios futuros.
+type ScheduledMedia struct {
+	Kind      MediaKind      `json:"kind"`
+	Mimetype  string         `json:"mimetype,omitempty"`
+	Filename  string         `json:"filename,omitempty"`
+	Caption   string         `json:"caption,omitempty"`
+	PTT       bool           `json:"ptt,omitempty"`
+	Thumbnail string         `json:"-" yaml:"thumbnail,omitempty"` // JPEG em base64
+	Quoted    *QuotedMessage `json:"quoted,omitempty"`
+	Mentions  []string       `json:"mentions,omitempty"`
+}
+
+// ScheduleResult é o corpo do evento "schedule", emitido a cada execução.
+// Ele informa só a entrada na fila: "queued" quer dizer que a mensagem foi
+// aceita pela fila com o id MessageID, e o envio em si é acompanhado pelos
+// eventos "queue" e "message_status" desse id.
+type ScheduleResult struct {
+	ScheduleID string    `json:"schedule_id"`
+	MessageID  string    `json:"message_id,omitempty"`
+	Status     string    `json:"status"` // queued (aceita pela fila) ou failed
+	Error      string    `json:"error,omitempty"`
+	RunAt      time.Time `json:"run_at"`
+}
+
+// scheduler guarda os agendamentos de uma instância.
+type scheduler struct {
+	mu        sync.Mutex
+	cron      *cron.Cron
+	schedules map[string]*Schedule
+	entries   map[string]cron.EntryID
+	timers    map[string]*time.Timer
+}
+
+func newScheduler() *scheduler {
+	s := &scheduler{
+		cron:      cron.New(),
+		schedules: make(map[string]*Schedule),
+		entries:   make(map[string]cron.EntryID),
+		timers:    make(map[string]*time.Timer),
+	}
+	s.cron.Start()
+	return s
+}
+
+// stop cancela todos os disparos pendentes, sem apagar os agendamentos salvos.
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron.Stop()
+	for _, t := range s.timers {
+		t.Stop()
+	}
+}
+
+// cronSpec monta a expressão cron com o fuso horário.
+func cronSpec(expr, timezone string) string {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return fmt.Sprintf("CRON_TZ=%s %s", timezone, expr)
+}
+
+// Schedule agenda a mensagem para to. Com cron, timezone é o fuso da expressão
+// (padrão UTC); sem cron, sendAt é o horário do envio único.
+func (i *Instancia) Schedule(to types.JID, msg *waE2E.Message, sendAt *time.Time, expr, timezone string, opts QueueOptions) (Schedule, error) {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	sc := newSchedule(to, opts)
+	sc.Payload = base64.StdEncoding.EncodeToString(payload)
+
+	return i.addSchedule(sc, nil, sendAt, expr, timezone)
+}
+
+// ScheduleMedia agenda o envio de uma mídia, com as mesmas regras de Schedule.
+// O arquivo fica guardado na sessão e o upload é feito em cada disparo.
+func (i *Instancia) ScheduleMedia(to types.JID, m Media, sendOpts SendOptions, sendAt *time.Time, expr, timezone string, opts QueueOptions) (Schedule, error) {
+	if len(m.Data) == 0 {
+		return Schedule{}, ErrEmptyMedia
+	}
+
+	sc := newSchedule(to, opts)
+	sc.Media = &ScheduledMedia{
+		Kind:     m.Kind,
+		Mimetype: m.Mimetype,
+		Filename: m.Filename,
+		Caption:  m.Caption,
+		PTT:      m.PTT,
+		Quoted:   sendOpts.Quoted,
+	}
+	if m.Thumbnail != nil {
+		sc.Media.Thumbnail = base64.StdEncoding.EncodeToString(m.Thumbnail)
+	}
+	for _, jid := range sendOpts.Mentions {
+		sc.Media.Mentions = append(sc.Media.Mentions, jid.String())
+	}
+
+	return i.addSchedule(sc, m.Data, sendAt, expr, timezone)
+}
+
+func newSchedule(to types.JID, opts QueueOptions) *Schedule {
+	return &Schedule{
+		ID:        uuid.NewString(),
+		To:        to.String(),
+		Priority:  opts.Priority,
+		Typing:    opts.Typing,
+		CreatedAt: time.Now(),
+	}
+}
+
+// addSchedule valida o horário, guarda o arquivo da mídia (se houver) e
+// programa o agendamento.
+func (i *Instancia) addSchedule(sc *Schedule, media []byte, sendAt *time.Time, expr, timezone string) (Schedule, error) {
+	switch {
+	case expr != "":
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return Schedule{}, fmt.Errorf("%w: %s", ErrInvalidCron, timezone)
+		}
+		if _, err := cron.ParseStandard(cronSpec(expr, timezone)); err != nil {
+			return Schedule{}, fmt.Errorf("%w: %v", ErrInvalidCron, err)
+		}
+		sc.Cron = expr
+		sc.Timezone = timezone
+	case sendAt != nil && sendAt.After(time.Now()):
+		sc.SendAt = sendAt
+	default:
+		return Schedule{}, ErrInvalidSchedule
+	}
+
+	if media != nil {
+		path := scheduleMediaPath(i.Id, sc.ID)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return Schedule{}, err
+		}
+		if err := os.WriteFile(path, media, 0644); err != nil {
+			return Schedule{}, err
+		}
+	}
+
+	if err := i.arm(sc); err != nil {
+		i.removeScheduleMedia(sc.ID)
+		return Schedule{}, err
+	}
+
+	if err := i.saveSchedules(); err != nil {
+		return Schedule{}, err
+	}
+
+	return i.scheduleView(sc), nil
+}
+
+// Schedules lista os agendamentos da instância, dos mais antigos para os mais novos.
+func (i *Instancia) Schedules() []Schedule {
+	i.sched.mu.Lock()
+	list := make([]*Schedule, 0, len(i.sched.schedules))
+	for _, sc := range i.sched.schedules {
+		list = append(list, sc)
+	}
+	i.sched.mu.Unlock()
+
+	slices.SortFunc(list, func(a, b *Schedule) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	views := make([]Schedule, 0, len(list))
+	for _, sc := range list {
+		views = append(views, i.scheduleView(sc))
+	}
+	return views
+}
+
+// CancelSchedule remove o agendamento. Retorna false se ele não existir.
+func (i *Instancia) CancelSchedule(id string) (bool, error) {
+	if !i.disarm(id) {
+		return false, nil
+	}
+	i.removeScheduleMedia(id)
+	return true, i.saveSchedules()
+}
+
+// arm registra o agendamento e programa o próximo disparo.
+func (i *Instancia) arm(sc *Schedule) error {
+	s := i.sched
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sc.Cron != "" {
+		entry, err := s.cron.AddFunc(cronSpec(sc.Cron, sc.Timezone), func() { i.fire(sc.ID) })
+		if err != nil {
+			return err
+		}
+		s.entries[sc.ID] = entry
+	} else {
+		// Envios que venceram com o processo parado saem assim que a instância é restaurada.
+		s.timers[sc.ID] = time.AfterFunc(time.Until(*sc.SendAt), func() { i.fire(sc.ID) })
+	}
+
+	s.schedules[sc.ID] = sc
+	return nil
+}
+
+// disarm cancela os disparos e esquece o agendamento.
+func (i *Instancia) disarm(id string) bool {
+	s := i.sched
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return false
+	}
+
+	if entry, ok := s.entries[id]; ok {
+		s.cron.Remove(entry)
+		delete(s.entries, id)
+	}
+	if t, ok := s.timers[id]; ok {
+		t.Stop()
+		delete(s.timers, id)
+	}
+	delete(s.schedules, id)
+	return true
+}
+
+// fire coloca a mensagem agendada na fila de envio e informa pelo evento
+// "schedule" se ela entrou na fila.
+func (i *Instancia) fire(id string) {
+	s := i.sched
+	s.mu.Lock()
+	sc, ok := s.schedules[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	sc.LastRun = &now
+	sc.Runs++
+	oneShot := sc.Cron == ""
+	payload, to, media := sc.Payload, sc.To, sc.Media
+	opts := QueueOptions{Priority: sc.Priority, Typing: sc.Typing}
+	s.mu.Unlock()
+
+	result := ScheduleResult{ScheduleID: id, Status: "queued", RunAt: now}
+
+	var (
+		item QueueItem
+		err  error
+	)
+	if media != nil {
+		item, err = i.enqueueMedia(id, to, *media, opts)
+	} else {
+		item, err = i.enqueuePayload(to, payload, opts)
+	}
+	if err != nil {
+		log.Errorf("Erro ao executar agendamento %s da instância %s: %v", id, i.Id, err)
+		result.Status = "failed"
+		result.Error = err.Error()
+	} else {
+		result.MessageID = item.ID
+	}
+
+	if oneShot && i.disarm(id) {
+		i.removeScheduleMedia(id)
+	}
+	if err := i.saveSchedules(); err != nil {
+		log.Errorf("Erro ao salvar agendamentos da instância %s: %v", i.Id, err)
+	}
+
+	i.emit(models.EventSchedule, result)
+}
+
+func (i *Instancia) enqueuePayload(to, payload string, opts QueueOptions) (QueueItem, error) {
+	jid, err := ParseRecipient(to)
+	if err != nil {
+		return QueueItem{}, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return QueueItem{}, err
+	}
+
+	msg := &waE2E.Message{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return QueueItem{}, err
+	}
+
+	return i.Enqueue(jid, msg, opts)
+}
+
+// enqueueMedia faz o upload da mídia guardada e coloca a mensagem na fila.
+func (i *Instancia) enqueueMedia(id, to string, sm ScheduledMedia, opts QueueOptions) (QueueItem, error) {
+	jid, err := ParseRecipient(to)
+	if err != nil {
+		return QueueItem{}, err
+	}
+
+	m := Media{
+		Kind:     sm.Kind,
+		Mimetype: sm.Mimetype,
+		Filename: sm.Filename,
+		Caption:  sm.Caption,
+		PTT:      sm.PTT,
+	}
+	if m.Data, err = os.ReadFile(scheduleMediaPath(i.Id, id)); err != nil {
+		return QueueItem{}, err
+	}
+	if sm.Thumbnail != "" {
+		if m.Thumbnail, err = base64.StdEncoding.DecodeString(sm.Thumbnail); err != nil {
+			return QueueItem{}, err
+		}
+	}
+
+	sendOpts := SendOptions{Quoted: sm.Quoted}
+	for _, mention := range sm.Mentions {
+		mjid, err := ParseRecipient(mention)
+		if err != nil {
+			return QueueItem{}, err
+		}
+		sendOpts.Mentions = append(sendOpts.Mentions, mjid)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scheduleUploadTimeout)
+	defer cancel()
+
+	msg, err := i.BuildMedia(ctx, m, sendOpts)
+	if err != nil {
+		return QueueItem{}, err
+	}
+
+	return i.Enqueue(jid, msg, opts)
+}
+
+// scheduleView copia o agendamento preenchendo o próximo disparo.
+func (i *Instancia) scheduleView(sc *Schedule) Schedule {
+	s := i.sched
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	view := *sc
+	if entry, ok := s.entries[sc.ID]; ok {
+		if next := s.cron.Entry(entry).Next; !next.IsZero() {
+			view.NextRun = &next
+		}
+	} else if sc.SendAt != nil {
+		view.NextRun = sc.SendAt
+	}
+	return view
+}
+
+func schedulesPath(id string) string {
+	return fmt.Sprintf("sessions/%s/schedules.yml", id)
+}
+
+// scheduleMediaPath é o arquivo com a mídia de um agendamento.
+func scheduleMediaPath(id, scheduleID string) string {
+	return fmt.Sprintf("sessions/%s/schedules/%s", id, scheduleID)
+}
+
+// removeScheduleMedia apaga o arquivo da mídia de um agendamento, se houver.
+func (i *Instancia) removeScheduleMedia(id string) {
+	err := os.Remove(scheduleMediaPath(i.Id, id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Erro ao apagar mídia do agendamento %s da instância %s: %v", id, i.Id, err)
+	}
+}
+
+// saveSchedules grava os agendamentos em sessions/<id>/schedules.yml.
+func (i *Instancia) saveSchedules() error {
+	s := i.sched
+	s.mu.Lock()
+	list := make([]Schedule, 0, len(s.schedules))
+	for _, sc := range s.schedules {
+		list = append(list, *sc)
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(list, func(a, b Schedule) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	data, err := yaml.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(schedulesPath(i.Id), data, 0644)
+}
+
+// loadSchedules restaura os agendamentos salvos. Chamado pelo Manager.Sync.
+func (i *Instancia) loadSchedules() error {
+	data, err := os.ReadFile(schedulesPath(i.Id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var list []Schedule
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	for n := range list {
+		if err := i.arm(&list[n]); err != nil {
+			log.Errorf("Erro ao restaurar agendamento %s da instância %s: %v", list[n].ID, i.Id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package maneger
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// scheduleResults devolve os resultados do evento "schedule" da instância.
+func scheduleResults(t *testing.T, i *Instancia) chan ScheduleResult {
+	t.Helper()
+
+	results := make(chan ScheduleResult, 8)
+	onEvent(t, func(inst *Instancia, evt models.Event) {
+		if inst == i && evt.Type == models.EventSchedule {
+			results <- evt.Data.(ScheduleResult)
+		}
+	})
+	return results
+}
+
+func waitResult(t *testing.T, results chan ScheduleResult) ScheduleResult {
+	t.Helper()
+
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("o agendamento não disparou")
+		return ScheduleResult{}
+	}
+}
+
+func TestScheduleCronTimezone(t *testing.T) {
+	withQueueConfig(t, config.QueueConfig{})
+	i := newTestInstance(t)
+
+	sc, err := i.Schedule(testRecipient, BuildText("bom dia", SendOptions{}), nil, "0 8 * * *", "America/Sao_Paulo", QueueOptions{})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	loc, _ := time.LoadLocation("America/Sao_Paulo")
+	if sc.NextRun == nil {
+		t.Fatal("agendamento sem next_run")
+	}
+	next := sc.NextRun.In(loc)
+	if next.Hour() != 8 || next.Minute() != 0 || time.Until(next) > 24*time.Hour {
+		t.Fatalf("next_run = %s, esperado as próximas 08:00 de São Paulo", next)
+	}
+
+	// Um disparo recorrente mantém o agendamento e conta a execução.
+	results := scheduleResults(t, i)
+	i.fire(sc.ID)
+	if r := waitResult(t, results); r.Status != "queued" || r.MessageID == "" {
+		t.Fatalf("resultado = %+v, esperado queued com o id da mensagem", r)
+	}
+
+	list := i.Schedules()
+	if len(list) != 1 || list[0].Runs != 1 || list[0].LastRun == nil {
+		t.Fatalf("agendamentos após o disparo = %+v, esperado 1 com runs 1", list)
+	}
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	i := newTestInstance(t)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		sendAt   *time.Time
+		cron, tz string
+		want     error
+	}{
+		{"sem horário", nil, "", "", ErrInvalidSchedule},
+		{"no passado", &past, "", "", ErrInvalidSchedule},
+		{"cron inválido", nil, "todo dia", "", ErrInvalidCron},
+		{"fuso inválido", nil, "0 8 * * *", "America/Atlantida", ErrInvalidCron},
+	}
+
+	for _, tt := range tests {
+		_, err := i.Schedule(testRecipient, BuildText("oi", SendOptions{}), tt.sendAt, tt.cron, tt.tz, QueueOptions{})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Schedule = %v, esperado %v", tt.name, err, tt.want)
+		}
+	}
+
+	if list := i.Schedules(); len(list) != 0 {
+		t.Fatalf("%d agendamentos inválidos registrados", len(list))
+	}
+}
+
+func TestScheduleOneShot(t *testing.T) {
+	withQueueConfig(t, config.QueueConfig{})
+	i := newTestInstance(t)
+	results := scheduleResults(t, i)
+
+	sendAt := time.Now().Add(50 * time.Millisecond)
+	sc, err := i.Schedule(testRecipient, BuildText("oi", SendOptions{}), &sendAt, "", "", QueueOptions{})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	if sc.NextRun == nil || !sc.NextRun.Equal(sendAt) {
+		t.Fatalf("next_run = %v, esperado %s", sc.NextRun, sendAt)
+	}
+
+	r := waitResult(t, results)
+	if r.ScheduleID != sc.ID || r.Status != "queued" || r.MessageID == "" {
+		t.Fatalf("resultado = %+v, esperado queued com o id da mensagem", r)
+	}
+
+	// O envio único sai da lista e do arquivo depois do disparo.
+	if list := i.Schedules(); len(list) != 0 {
+		t.Fatalf("agendamentos após o disparo = %+v, esperado nenhum", list)
+	}
+	j := &Instancia{Id: i.Id, sched: newScheduler()}
+	t.Cleanup(j.sched.stop)
+	if err := j.loadSchedules(); err != nil {
+		t.Fatalf("loadSchedules: %v", err)
+	}
+	if list := j.Schedules(); len(list) != 0 {
+		t.Fatalf("agendamentos salvos após o disparo = %+v, esperado nenhum", list)
+	}
+}
+
+func TestScheduleRestore(t *testing.T) {
+	i := newTestInstance(t)
+
+	sc, err := i.Schedule(testRecipient, BuildText("oi", SendOptions{}), nil, "*/5 * * * *", "", QueueOptions{Priority: PriorityHigh})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	j := &Instancia{Id: i.Id, sched: newScheduler()}
+	t.Cleanup(j.sched.stop)
+	if err := j.loadSchedules(); err != nil {
+		t.Fatalf("loadSchedules: %v", err)
+	}
+
+	list := j.Schedules()
+	if len(list) != 1 {
+		t.Fatalf("%d agendamentos restaurados, esperado 1", len(list))
+	}
+	got := list[0]
+	if got.ID != sc.ID || got.Cron != sc.Cron || got.Priority != PriorityHigh || got.Payload != sc.Payload || got.NextRun == nil {
+		t.Fatalf("agendamento restaurado = %+v, esperado %+v", got, sc)
+	}
+}
+
+// TestScheduleMediaUploadsOnFire garante que a mídia é guardada crua e que o
+// upload só acontece no disparo: sem login, o agendamento é aceito e o
+// disparo falha.
+func TestScheduleMediaUploadsOnFire(t *testing.T) {
+	i := newTestInstance(t)
+	results := scheduleResults(t, i)
+
+	data := []byte("%PDF-1.4 conteúdo")
+	media := Media{Kind: MediaDocument, Data: data, Filename: "boleto.pdf", Caption: "Seu boleto"}
+	opts := SendOptions{Quoted: &QuotedMessage{Id: "ABC", Text: "oi"}, Mentions: []types.JID{testRecipient}}
+
+	sc, err := i.ScheduleMedia(testRecipient, media, opts, nil, "0 8 * * *", "", QueueOptions{})
+	if err != nil {
+		t.Fatalf("ScheduleMedia: %v", err)
+	}
+	if sc.Payload != "" || sc.Media == nil || sc.Media.Filename != "boleto.pdf" {
+		t.Fatalf("agendamento = %+v, esperado a mídia sem payload", sc)
+	}
+
+	path := scheduleMediaPath(i.Id, sc.ID)
+	if saved, err := os.ReadFile(path); err != nil || !bytes.Equal(saved, data) {
+		t.Fatalf("arquivo da mídia = %q (%v), esperado %q", saved, err, data)
+	}
+
+	j := &Instancia{Id: i.Id, sched: newScheduler()}
+	t.Cleanup(j.sched.stop)
+	if err := j.loadSchedules(); err != nil {
+		t.Fatalf("loadSchedules: %v", err)
+	}
+	if list := j.Schedules(); len(list) != 1 || list[0].Media == nil || list[0].Media.Quoted == nil ||
+		len(list[0].Media.Mentions) != 1 || list[0].Media.Mentions[0] != testRecipient.String() {
+		t.Fatalf("agendamentos restaurados = %+v, esperado a mídia com citação e menção", list)
+	}
+
+	i.fire(sc.ID)
+	if r := waitResult(t, results); r.Status != "failed" || r.Error != ErrNotLoggedIn.Error() {
+		t.Fatalf("resultado = %+v, esperado failed por falta de login", r)
+	}
+
+	if _, err := i.CancelSchedule(sc.ID); err != nil {
+		t.Fatalf("CancelSchedule: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("arquivo da mídia após o cancelamento: %v, esperado inexistente", err)
+	}
+}
@@ -0,0 +1,183 @@
+package maneger
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrNotLoggedIn      = errors.New("sessão não está conectada ao WhatsApp")
+	ErrInvalidRecipient = errors.New("número ou JID inválido")
+	ErrNotOnWhatsApp    = errors.New("número não está no WhatsApp")
+)
+
+// QuotedMessage identifica a mensagem que está sendo respondida.
+type QuotedMessage struct {
+	Id     string `json:"id"`
+	Sender string `json:"sender"`
+	Text   string `json:"text"`
+}
+
+// SendOptions são os campos opcionais comuns a todos os tipos de mensagem.
+type SendOptions struct {
+	Quoted   *QuotedMessage
+	Mentions []types.JID
+}
+
+// ParseRecipient converte um número de telefone ou JID em types.JID.
+func ParseRecipient(to string) (types.JID, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return types.EmptyJID, ErrInvalidRecipient
+	}
+
+	if strings.Contains(to, "@") {
+		jid, err := types.ParseJID(to)
+		if err != nil || jid.User == "" {
+			return types.EmptyJID, ErrInvalidRecipient
+		}
+		return jid, nil
+	}
+
+	number := onlyDigits(to)
+	if len(number) < 8 {
+		return types.EmptyJID, ErrInvalidRecipient
+	}
+
+	return types.NewJID(number, types.DefaultUserServer), nil
+}
+
+// ResolveRecipient converte o destino em JID e, para contatos individuais,
+// confirma que o número está no WhatsApp.
+func (i *Instancia) ResolveRecipient(ctx context.Context, to string) (types.JID, error) {
+	jid, err := ParseRecipient(to)
+	if err != nil {
+		return jid, err
+	}
+
+	if jid.Server != types.DefaultUserServer {
+		return jid, nil
+	}
+
+	if !i.Client().IsLoggedIn() {
+		return jid, ErrNotLoggedIn
+	}
+
+	res, err := i.Client().IsOnWhatsApp(ctx, []string{"+" + jid.User})
+	if err != nil {
+		return jid, err
+	}
+	if len(res) == 0 || !res[0].IsIn {
+		return jid, ErrNotOnWhatsApp
+	}
+
+	return res[0].JID, nil
+}
+
+// Send envia uma mensagem já montada. Todo envio da instância passa por aqui,
+// inclusive os da fila (veja Enqueue), e a situação de cada mensagem enviada
+// é acompanhada até a leitura (veja MessageStatus) e guardada no histórico.
+func (i *Instancia) Send(ctx context.Context, to types.JID, msg *waE2E.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error) {
+	if i.Stopped.Load() || !i.Client().IsLoggedIn() {
+		return whatsmeow.SendResponse{}, ErrNotLoggedIn
+	}
+
+	if err := i.rateLimiter().Allow(); err != nil {
+		return whatsmeow.SendResponse{}, err
+	}
+
+	var req whatsmeow.SendRequestExtra
+	if len(extra) > 0 {
+		req = extra[0]
+	}
+	if req.ID == "" {
+		req.ID = i.Client().GenerateMessageID()
+	}
+
+	chat := to.ToNonAD().String()
+	i.trackStatus(models.StatusChange{
+		MessageID: req.ID,
+		Chat:      chat,
+		Status:    models.StatusPending,
+		Timestamp: time.Now(),
+	}, true)
+
+	resp, err := i.Client().SendMessage(ctx, to, msg, req)
+	if err != nil {
+		i.forgetStatus(req.ID)
+		return resp, err
+	}
+
+	i.trackStatus(models.StatusChange{
+		MessageID: req.ID,
+		Chat:      chat,
+		Status:    models.StatusServerAck,
+		Timestamp: resp.Timestamp,
+	}, false)
+
+	if database.Available() {
+		i.storeSent(req.ID, to, msg, resp.Timestamp)
+	}
+
+	return resp, nil
+}
+
+// BuildText monta uma mensagem de texto. Usa ExtendedTextMessage quando há
+// resposta ou menções, pois Conversation não aceita ContextInfo.
+func BuildText(text string, opts SendOptions) *waE2E.Message {
+	info := opts.contextInfo()
+	if info == nil {
+		return &waE2E.Message{Conversation: proto.String(text)}
+	}
+
+	return &waE2E.Message{
+		ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+			Text:        proto.String(text),
+			ContextInfo: info,
+		},
+	}
+}
+
+// contextInfo monta o ContextInfo com a mensagem citada e as menções.
+func (o SendOptions) contextInfo() *waE2E.ContextInfo {
+	if o.Quoted == nil && len(o.Mentions) == 0 {
+		return nil
+	}
+
+	info := &waE2E.ContextInfo{}
+
+	if q := o.Quoted; q != nil {
+		info.StanzaID = proto.String(q.Id)
+		if q.Sender != "" {
+			if jid, err := ParseRecipient(q.Sender); err == nil {
+				info.Participant = proto.String(jid.ToNonAD().String())
+			}
+		}
+		info.QuotedMessage = &waE2E.Message{Conversation: proto.String(q.Text)}
+	}
+
+	for _, jid := range o.Mentions {
+		info.MentionedJID = append(info.MentionedJID, jid.String())
+	}
+
+	return info
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
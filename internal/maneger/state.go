@@ -0,0 +1,124 @@
+package maneger
+
+import (
+	"slices"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/models"
+)
+
+// historySize é quantas transições ficam guardadas em memória por instância.
+const historySize = 50
+
+// transitions lista para quais estados cada estado pode ir.
+var transitions = map[models.State][]models.State{
+	models.StateCreated: {
+		models.StateAwaitingQR, models.StatePairing, models.StateConnecting, models.StateStopped,
+	},
+	models.StateAwaitingQR: {
+		models.StatePairing, models.StateConnecting, models.StateConnected, models.StateDisconnected, models.StateStopped,
+	},
+	models.StatePairing: {
+		models.StateAwaitingQR, models.StateConnecting, models.StateConnected, models.StateDisconnected, models.StateStopped,
+	},
+	models.StateConnecting: {
+		models.StateAwaitingQR, models.StateConnected, models.StateDisconnected, models.StateLoggedOut, models.StateBanned, models.StateStopped,
+	},
+	models.StateConnected: {
+		models.StateConnecting, models.StateDisconnected, models.StateLoggedOut, models.StateBanned, models.StateStopped,
+	},
+	models.StateDisconnected: {
+		models.StateAwaitingQR, models.StatePairing, models.StateConnecting, models.StateConnected, models.StateLoggedOut, models.StateBanned, models.StateStopped,
+	},
+	models.StateLoggedOut: {
+		models.StateAwaitingQR, models.StatePairing, models.StateStopped,
+	},
+	models.StateBanned: {
+		models.StateConnecting, models.StateConnected, models.StateLoggedOut, models.StateStopped,
+	},
+	models.StateStopped: {
+		models.StateAwaitingQR, models.StatePairing, models.StateConnecting, models.StateLoggedOut,
+	},
+}
+
+// CanTransition informa se a instância pode ir de from para to.
+func CanTransition(from, to models.State) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// setState valida e registra a transição, emitindo o evento "connection".
+// Transições para o estado atual são ignoradas e as inválidas apenas logadas.
+// Não pode ser chamado com i.Mu travado, já que os handlers do evento leem a
+// instância; nesses casos use transition e, depois de destravar, announce.
+func (i *Instancia) setState(to models.State, reason string) {
+	if t, ok := i.transition(to, reason); ok {
+		i.announce(t)
+	}
+}
+
+// transition valida e registra a transição sem emitir o evento. Retorna false
+// se ela foi ignorada.
+func (i *Instancia) transition(to models.State, reason string) (models.Transition, bool) {
+	i.stateMu.Lock()
+	defer i.stateMu.Unlock()
+
+	from := i.state
+	if from == to {
+		return models.Transition{}, false
+	}
+
+	if !CanTransition(from, to) {
+		log.Warnf("Transição inválida da instância %s: %s -> %s (%s)", i.Id, from, to, reason)
+		return models.Transition{}, false
+	}
+
+	t := models.Transition{
+		State:     to,
+		Previous:  from,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}
+
+	i.state = to
+	i.stateSince = t.Timestamp
+	i.history = append(i.history, t)
+	if len(i.history) > historySize {
+		i.history = i.history[len(i.history)-historySize:]
+	}
+
+	log.Infof("Instância %s: %s -> %s %s", i.Id, from, to, reason)
+	return t, true
+}
+
+// announce emite o evento "connection" das transições registradas por
+// transition e atualiza o estado no banco.
+func (i *Instancia) announce(ts ...models.Transition) {
+	if len(ts) == 0 {
+		return
+	}
+
+	for _, t := range ts {
+		i.emit(models.EventConnection, t)
+	}
+
+	if database.Available() {
+		i.syncStatus()
+	}
+}
+
+// State retorna o estado atual da instância e desde quando ela está nele.
+func (i *Instancia) State() (models.State, time.Time) {
+	i.stateMu.RLock()
+	defer i.stateMu.RUnlock()
+
+	return i.state, i.stateSince
+}
+
+// History retorna as últimas transições de estado, da mais antiga para a mais recente.
+func (i *Instancia) History() []models.Transition {
+	i.stateMu.RLock()
+	defer i.stateMu.RUnlock()
+	return slices.Clone(i.history)
+}
@@ -0,0 +1,284 @@
+package maneger
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/models"
+)
+
+// newTestInstance abre uma instância em um diretório temporário. O cliente
+// usa um proxy inexistente, então as tentativas de conexão falham sem rede.
+func newTestInstance(t *testing.T) *Instancia {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	i, err := openInstance("teste")
+	if err != nil {
+		t.Fatalf("openInstance: %v", err)
+	}
+	if err := i.Client().SetProxyAddress("socks5://127.0.0.1:1"); err != nil {
+		t.Fatalf("SetProxyAddress: %v", err)
+	}
+	t.Cleanup(func() {
+		// Num deadlock, close ficaria preso em i.Mu.
+		if !t.Failed() {
+			i.close()
+		}
+	})
+
+	return i
+}
+
+// onEvent registra um handler apenas durante o teste.
+func onEvent(t *testing.T, h EventHandler) {
+	t.Helper()
+
+	handlersMu.Lock()
+	saved := handlers
+	handlers = append(handlers[:len(handlers):len(handlers)], h)
+	handlersMu.Unlock()
+
+	t.Cleanup(func() {
+		// Num deadlock, o handler ainda segura handlersMu.
+		if t.Failed() {
+			return
+		}
+		handlersMu.Lock()
+		handlers = saved
+		handlersMu.Unlock()
+	})
+}
+
+// transitionLog guarda as transições recebidas pelo evento "connection".
+type transitionLog struct {
+	mu     sync.Mutex
+	states []models.State
+	seen   chan models.State
+}
+
+func (l *transitionLog) has(state models.State) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, s := range l.states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// waitFor aguarda a transição para state.
+func (l *transitionLog) waitFor(t *testing.T, state models.State) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for !l.has(state) {
+		select {
+		case <-l.seen:
+		case <-timeout:
+			t.Fatalf("transição para %s não foi anunciada", state)
+		}
+	}
+}
+
+// withTimeout falha o teste se fn não terminar a tempo, como num deadlock.
+func withTimeout(t *testing.T, name string, fn func() error) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s não terminou: deadlock?", name)
+	}
+}
+
+// TestStartStopWithWebhookHandler garante que Start e Stop não anunciam as
+// transições segurando i.Mu: o dispatcher de webhooks lê a instância dentro
+// do handler.
+func TestStartStopWithWebhookHandler(t *testing.T) {
+	i := newTestInstance(t)
+
+	log := &transitionLog{seen: make(chan models.State, 16)}
+	onEvent(t, func(inst *Instancia, evt models.Event) {
+		if inst != i || evt.Type != models.EventConnection {
+			return
+		}
+
+		// Como em webhook.Dispatcher.Handle.
+		inst.WebhookTarget()
+		inst.Secret()
+
+		tr := evt.Data.(models.Transition)
+		log.mu.Lock()
+		log.states = append(log.states, tr.State)
+		log.mu.Unlock()
+
+		select {
+		case log.seen <- tr.State:
+		default:
+		}
+	})
+
+	withTimeout(t, "Start", i.Start)
+	log.waitFor(t, models.StateAwaitingQR)
+
+	withTimeout(t, "Stop", i.Stop)
+	log.waitFor(t, models.StateStopped)
+
+	if state, _ := i.State(); state != models.StateStopped {
+		t.Fatalf("estado = %s, esperado %s", state, models.StateStopped)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to models.State
+		want     bool
+	}{
+		{models.StateCreated, models.StateAwaitingQR, true},
+		{models.StateAwaitingQR, models.StateConnected, true},
+		{models.StateConnected, models.StateDisconnected, true},
+		{models.StateDisconnected, models.StateConnecting, true},
+		{models.StateStopped, models.StateConnecting, true},
+		{models.StateCreated, models.StateConnected, false},
+		{models.StateLoggedOut, models.StateConnected, false},
+		{models.StateStopped, models.StateConnected, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, esperado %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestSetStateRecordsHistory(t *testing.T) {
+	i := &Instancia{Id: "teste", state: models.StateCreated}
+
+	i.setState(models.StateAwaitingQR, "qr")
+	i.setState(models.StateAwaitingQR, "repetida") // ignorada
+	i.setState(models.StateLoggedOut, "inválida")  // ignorada
+	i.setState(models.StateConnected, "conectada")
+
+	if state, _ := i.State(); state != models.StateConnected {
+		t.Fatalf("estado = %s, esperado %s", state, models.StateConnected)
+	}
+
+	if len(i.history) != 2 {
+		t.Fatalf("histórico com %d transições, esperado 2: %+v", len(i.history), i.history)
+	}
+	if h := i.history[1]; h.Previous != models.StateAwaitingQR || h.State != models.StateConnected || h.Reason != "conectada" {
+		t.Fatalf("transição inesperada: %+v", h)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	i := &Instancia{Id: "teste", state: models.StateConnected}
+
+	for n := 0; n < historySize; n++ {
+		i.setState(models.StateDisconnected, "caiu")
+		i.setState(models.StateConnected, "voltou")
+	}
+
+	if len(i.history) != historySize {
+		t.Fatalf("histórico com %d transições, esperado %d", len(i.history), historySize)
+	}
+	if last := i.history[len(i.history)-1]; last.State != models.StateConnected {
+		t.Fatalf("última transição = %s, esperado %s", last.State, models.StateConnected)
+	}
+}
+
+// TestGetQRStartsSingleFlow garante que GetQR não inicia um segundo fluxo de
+// QR enquanto o primeiro ainda aguarda o código.
+func TestGetQRStartsSingleFlow(t *testing.T) {
+	i := newTestInstance(t)
+	i.Stopped.Store(false)
+
+	events := make(chan *QRCodeEvent, 4)
+	onEvent(t, func(inst *Instancia, evt models.Event) {
+		if inst == i && evt.Type == models.EventQR {
+			events <- evt.Data.(*QRCodeEvent)
+		}
+	})
+
+	// Simula um fluxo em andamento.
+	i.qrFlow.Store(true)
+	if _, err := i.GetQR(); !errors.Is(err, ErrQRPending) {
+		t.Fatalf("GetQR = %v, esperado %v", err, ErrQRPending)
+	}
+	select {
+	case evt := <-events:
+		t.Fatalf("GetQR iniciou outro fluxo, que terminou com %q", evt.Event)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Sem fluxo, GetQR inicia um; a conexão falha pelo proxy inválido.
+	i.qrFlow.Store(false)
+	if _, err := i.GetQR(); !errors.Is(err, ErrQRPending) {
+		t.Fatalf("GetQR = %v, esperado %v", err, ErrQRPending)
+	}
+	select {
+	case evt := <-events:
+		if evt.Event != QREventError {
+			t.Fatalf("fluxo terminou com %q, esperado %q", evt.Event, QREventError)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetQR não iniciou o fluxo")
+	}
+
+	waitQRFlow(t, i)
+}
+
+// waitQRFlow aguarda o fluxo de QR em andamento terminar e limpar a flag.
+func waitQRFlow(t *testing.T, i *Instancia) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for i.qrFlow.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("flag do fluxo não foi limpa ao terminar")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRestartedQRFlowAwaitsQR garante que um fluxo reiniciado depois de um
+// QR expirado volta ao estado awaiting_qr.
+func TestRestartedQRFlowAwaitsQR(t *testing.T) {
+	i := newTestInstance(t)
+	i.Stopped.Store(false)
+	i.setState(models.StateConnecting, "iniciada")
+	i.setState(models.StateDisconnected, "QR code expirado")
+
+	log := &transitionLog{seen: make(chan models.State, 16)}
+	onEvent(t, func(inst *Instancia, evt models.Event) {
+		if inst != i || evt.Type != models.EventConnection {
+			return
+		}
+		tr := evt.Data.(models.Transition)
+		log.mu.Lock()
+		log.states = append(log.states, tr.State)
+		log.mu.Unlock()
+
+		select {
+		case log.seen <- tr.State:
+		default:
+		}
+	})
+
+	if _, err := i.GetQR(); !errors.Is(err, ErrQRPending) {
+		t.Fatalf("GetQR = %v, esperado %v", err, ErrQRPending)
+	}
+	log.waitFor(t, models.StateAwaitingQR)
+	waitQRFlow(t, i)
+}
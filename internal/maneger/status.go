@@ -0,0 +1,230 @@
+package maneger
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// statusTracker guarda a situação das últimas mensagens enviadas. Com banco
+// de dados, as mais antigas continuam disponíveis por lá.
+type statusTracker struct {
+	mu    sync.Mutex
+	items map[string]*models.MessageStatus
+	order []string
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{items: make(map[string]*models.MessageStatus)}
+}
+
+// add guarda a mensagem, descartando as mais antigas. Chamado com mu travado.
+func (t *statusTracker) add(st *models.MessageStatus) {
+	t.items[st.ID] = st
+	t.order = append(t.order, st.ID)
+	if len(t.order) > keepFinished {
+		delete(t.items, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// forget descarta uma mensagem cujo envio falhou.
+func (t *statusTracker) forget(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.items, id)
+	if n := slices.Index(t.order, id); n >= 0 {
+		t.order = slices.Delete(t.order, n, n+1)
+	}
+}
+
+// MessageStatus retorna a situação de uma mensagem enviada pela instância.
+func (i *Instancia) MessageStatus(id string) (models.MessageStatus, bool) {
+	i.statuses.mu.Lock()
+	st, ok := i.statuses.items[id]
+	if ok {
+		snapshot := *st
+		snapshot.Participants = maps.Clone(st.Participants)
+		snapshot.Timeline = slices.Clone(st.Timeline)
+		i.statuses.mu.Unlock()
+		return snapshot, true
+	}
+	i.statuses.mu.Unlock()
+
+	if !database.Available() {
+		return models.MessageStatus{}, false
+	}
+	st, ok = loadStatuses(i.Id, []string{id})[id]
+	if !ok {
+		return models.MessageStatus{}, false
+	}
+	return *st, true
+}
+
+// trackStatus registra a mudança de situação e emite o evento
+// "message_status". Mensagens desconhecidas só são criadas com create.
+func (i *Instancia) trackStatus(c models.StatusChange, create bool) {
+	i.trackStatuses([]models.StatusChange{c}, create)
+}
+
+// trackStatuses registra várias mudanças de uma vez. As mensagens que não
+// estão em memória são buscadas no banco numa única consulta, fora do lock.
+func (i *Instancia) trackStatuses(changes []models.StatusChange, create bool) {
+	t := i.statuses
+
+	var missing []string
+	t.mu.Lock()
+	for _, c := range changes {
+		if _, ok := t.items[c.MessageID]; !ok {
+			missing = append(missing, c.MessageID)
+		}
+	}
+	t.mu.Unlock()
+
+	var loaded map[string]*models.MessageStatus
+	if len(missing) > 0 && database.Available() {
+		loaded = loadStatuses(i.Id, missing)
+	}
+
+	var applied []models.StatusChange
+	t.mu.Lock()
+	for _, c := range changes {
+		st, ok := t.items[c.MessageID]
+		if !ok {
+			if st, ok = loaded[c.MessageID]; ok {
+				t.add(st)
+			}
+		}
+		if !ok {
+			if !create {
+				continue
+			}
+			st = &models.MessageStatus{ID: c.MessageID, Chat: c.Chat}
+			t.add(st)
+		}
+
+		if c.Chat == "" {
+			c.Chat = st.Chat
+		}
+		if st.Apply(c) {
+			applied = append(applied, c)
+		}
+	}
+	t.mu.Unlock()
+
+	if len(applied) == 0 {
+		return
+	}
+
+	if database.Available() {
+		rows := make([]dbmodels.MessageStatusChange, len(applied))
+		for n, c := range applied {
+			rows[n] = dbmodels.MessageStatusChange{
+				InstanceID:  i.Id,
+				MessageID:   c.MessageID,
+				Chat:        c.Chat,
+				Participant: c.Participant,
+				Status:      c.Status,
+				Timestamp:   c.Timestamp,
+			}
+		}
+		if err := database.Instance().Create(&rows).Error; err != nil {
+			log.Errorf("Erro ao salvar situação das mensagens da instância %s: %v", i.Id, err)
+		}
+	}
+
+	for _, c := range applied {
+		i.emit(models.EventStatus, c)
+	}
+}
+
+// forgetStatus descarta o registro de uma mensagem que não chegou a ser enviada.
+func (i *Instancia) forgetStatus(id string) {
+	i.statuses.forget(id)
+
+	if database.Available() {
+		err := database.Instance().Where("instance_id = ? AND message_id = ?", i.Id, id).
+			Delete(&dbmodels.MessageStatusChange{}).Error
+		if err != nil {
+			log.Errorf("Erro ao remover situação da mensagem %s: %v", id, err)
+		}
+	}
+}
+
+// handleReceipt leva as confirmações do WhatsApp às mensagens enviadas. Em
+// grupos, cada participante é registrado separadamente.
+func (i *Instancia) handleReceipt(evt *events.Receipt) {
+	var status string
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		status = models.StatusDelivered
+	case types.ReceiptTypeRead:
+		status = models.StatusRead
+	case types.ReceiptTypePlayed:
+		status = models.StatusPlayed
+	default:
+		return
+	}
+
+	var participant string
+	if evt.IsGroup {
+		participant = evt.Sender.ToNonAD().String()
+	}
+
+	timestamp := evt.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	changes := make([]models.StatusChange, len(evt.MessageIDs))
+	for n, id := range evt.MessageIDs {
+		changes[n] = models.StatusChange{
+			MessageID:   id,
+			Chat:        evt.Chat.String(),
+			Participant: participant,
+			Status:      status,
+			Timestamp:   timestamp,
+		}
+	}
+	i.trackStatuses(changes, false)
+}
+
+// loadStatuses refaz a situação das mensagens a partir das mudanças salvas.
+// As que não têm nenhuma mudança ficam fora do mapa.
+func loadStatuses(instance string, ids []string) map[string]*models.MessageStatus {
+	var rows []dbmodels.MessageStatusChange
+	err := database.Instance().
+		Where("instance_id = ? AND message_id IN ?", instance, ids).
+		Order("id").
+		Find(&rows).Error
+	if err != nil {
+		log.Errorf("Erro ao buscar situação das mensagens da instância %s: %v", instance, err)
+		return nil
+	}
+
+	statuses := make(map[string]*models.MessageStatus)
+	for _, row := range rows {
+		st, ok := statuses[row.MessageID]
+		if !ok {
+			st = &models.MessageStatus{ID: row.MessageID, Chat: row.Chat}
+			statuses[row.MessageID] = st
+		}
+		st.Apply(models.StatusChange{
+			MessageID:   row.MessageID,
+			Chat:        row.Chat,
+			Participant: row.Participant,
+			Status:      row.Status,
+			Timestamp:   row.Timestamp,
+		})
+	}
+	return statuses
+}
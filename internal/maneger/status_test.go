@@ -0,0 +1,50 @@
+package maneger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// TestReceiptLoadsStatusesFromDatabase simula um recibo que chega depois de
+// um reinício: as mensagens só existem no banco.
+func TestReceiptLoadsStatusesFromDatabase(t *testing.T) {
+	openTestDatabase(t)
+
+	chat := historyChat.String()
+	sent := &Instancia{Id: "teste", statuses: newStatusTracker()}
+	for _, id := range []string{"msg-1", "msg-2"} {
+		sent.trackStatus(models.StatusChange{MessageID: id, Chat: chat, Status: models.StatusPending, Timestamp: historyStart}, true)
+		sent.trackStatus(models.StatusChange{MessageID: id, Status: models.StatusServerAck, Timestamp: historyStart}, false)
+	}
+
+	i := &Instancia{Id: "teste", statuses: newStatusTracker()}
+	i.handleReceipt(&events.Receipt{
+		MessageSource: types.MessageSource{Chat: historyChat},
+		MessageIDs:    []types.MessageID{"msg-1", "msg-2", "desconhecida"},
+		Timestamp:     historyStart.Add(time.Minute),
+		Type:          types.ReceiptTypeRead,
+	})
+
+	for _, id := range []string{"msg-1", "msg-2"} {
+		st, ok := i.MessageStatus(id)
+		if !ok || st.Status != models.StatusRead || len(st.Timeline) != 3 {
+			t.Fatalf("situação de %s = %+v, esperado read após 3 mudanças", id, st)
+		}
+	}
+
+	if _, ok := i.MessageStatus("desconhecida"); ok {
+		t.Fatal("recibo de uma mensagem desconhecida criou uma situação")
+	}
+
+	var count int64
+	database.Instance().Model(&dbmodels.MessageStatusChange{}).Where("instance_id = ?", i.Id).Count(&count)
+	if count != 6 {
+		t.Fatalf("%d mudanças salvas, esperado 6", count)
+	}
+}
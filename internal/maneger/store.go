@@ -0,0 +1,377 @@
+package maneger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// Onde os aparelhos do whatsmeow são guardados (whatsapp.store).
+const (
+	StoreSession  = "session"  // sessions/<id>/store.db, um arquivo por instância
+	StoreDatabase = "database" // um único store no banco configurado, com vários aparelhos
+)
+
+// Store compartilhado entre as instâncias quando whatsapp.store é database.
+var (
+	sharedMu        sync.Mutex
+	sharedContainer *sqlstore.Container
+	sharedDB        *sql.DB
+	sharedDial      string
+)
+
+func sharedStoreEnabled() bool {
+	return config.Get().Whatsapp.Store == StoreDatabase
+}
+
+// openSharedStore abre, uma única vez, o store compartilhado no banco
+// configurado em database. Usa uma conexão própria, separada da do gorm.
+func openSharedStore(ctx context.Context) (*sqlstore.Container, *sql.DB, string, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if sharedContainer != nil {
+		return sharedContainer, sharedDB, sharedDial, nil
+	}
+
+	cfg := database.FromConfig(config.Get().Database)
+
+	var dialect, dsn string
+	switch cfg.Driver {
+	case "postgres":
+		dialect, dsn = "pgx", cfg.DSN
+	case "sqlite":
+		dialect, dsn = "sqlite3", database.SQLiteDSN(cfg.DSN)
+	default:
+		return nil, nil, "", fmt.Errorf("driver não suportado pelo store do whatsmeow: %s", cfg.Driver)
+	}
+
+	db, err := sql.Open(dialect, dsn)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if strings.Contains(dsn, ":memory:") {
+		// Cada conexão com ":memory:" abriria um banco diferente.
+		db.SetMaxOpenConns(1)
+	}
+
+	container := sqlstore.NewWithDB(db, dialect, waLog.Noop)
+	if err := container.Upgrade(ctx); err != nil {
+		db.Close()
+		return nil, nil, "", fmt.Errorf("erro ao atualizar o store do whatsmeow: %w", err)
+	}
+
+	sharedContainer, sharedDB, sharedDial = container, db, dialect
+	return container, db, dialect, nil
+}
+
+// closeSharedStore fecha o store compartilhado. Chamado no desligamento,
+// depois que todas as instâncias foram fechadas.
+func closeSharedStore() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if sharedContainer == nil {
+		return
+	}
+	if err := sharedContainer.Close(); err != nil {
+		log.Errorf("Erro ao fechar o store compartilhado: %v", err)
+	}
+	sharedContainer, sharedDB, sharedDial = nil, nil, ""
+}
+
+// openStore abre o store do aparelho da instância: o store.db em dir ou, com
+// whatsapp.store: database, o aparelho dela no store compartilhado. Retorna
+// um aparelho novo se a instância ainda não foi pareada.
+func openStore(ctx context.Context, id, dir string) (*sqlstore.Container, *store.Device, bool, error) {
+	if !sharedStoreEnabled() {
+		dbpath := fmt.Sprintf("file:%s/store.db?_foreign_keys=on", dir)
+		container, err := sqlstore.New(ctx, "sqlite3", dbpath, waLog.Noop)
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		device, err := container.GetFirstDevice(ctx)
+		return container, device, false, err
+	}
+
+	container, _, _, err := openSharedStore(ctx)
+	if err != nil {
+		return nil, nil, true, err
+	}
+
+	if jid, ok := deviceJID(id); ok {
+		device, err := container.GetDevice(ctx, jid)
+		if err != nil {
+			return nil, nil, true, err
+		}
+		if device != nil {
+			return container, device, true, nil
+		}
+		log.Warnf("Aparelho %s da instância %s não está no store compartilhado", jid, id)
+	}
+
+	return container, container.NewDevice(), true, nil
+}
+
+// deviceJID retorna o aparelho da instância, salvo no banco ou no session.yml.
+func deviceJID(id string) (types.JID, bool) {
+	var device string
+
+	if database.Available() {
+		var row dbmodels.Instance
+		if err := database.Instance().Select("device_jid").Where("id = ?", id).Limit(1).Find(&row).Error; err != nil {
+			log.Errorf("Erro ao buscar aparelho da instância %s: %v", id, err)
+		}
+		device = row.Device
+	}
+
+	if device == "" {
+		if s, err := readSessionYml(id); err == nil && s != nil {
+			device = s.Device
+		}
+	}
+
+	if device == "" {
+		return types.EmptyJID, false
+	}
+
+	jid, err := types.ParseJID(device)
+	if err != nil {
+		log.Errorf("Aparelho da instância %s é inválido (%s): %v", id, device, err)
+		return types.EmptyJID, false
+	}
+	return jid, true
+}
+
+// ImportStores copia os aparelhos dos sessions/<id>/store.db para o store
+// compartilhado e associa cada um à sua instância. Pode ser executado mais de
+// uma vez: o que já foi importado é mantido. Retorna quantos aparelhos foram
+// importados.
+func ImportStores(ctx context.Context) (int, error) {
+	_, dst, dialect, err := openSharedStore(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	dirs, err := os.ReadDir("sessions")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	imported := 0
+	for _, d := range dirs {
+		path := filepath.Join("sessions", d.Name(), "store.db")
+		if !d.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		jid, err := importStore(ctx, path, dst, dialect)
+		if err != nil {
+			return imported, fmt.Errorf("%s: %w", path, err)
+		}
+		if jid.IsEmpty() {
+			log.Infof("Instância %s não está pareada, nada a importar", d.Name())
+			continue
+		}
+
+		if err := setDevice(d.Name(), jid); err != nil {
+			return imported, err
+		}
+
+		log.Infof("Aparelho %s da instância %s importado", jid, d.Name())
+		imported++
+	}
+
+	return imported, nil
+}
+
+// importStore copia as tabelas do whatsmeow de um store.db para dst e retorna
+// o aparelho encontrado. O arquivo original não é alterado.
+func importStore(ctx context.Context, path string, dst *sql.DB, dialect string) (types.JID, error) {
+	snapshot, err := snapshotStore(ctx, path)
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	defer os.RemoveAll(filepath.Dir(snapshot))
+
+	// Abrir pelo sqlstore atualiza o esquema da cópia para o mesmo do destino.
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on", snapshot)
+	container, err := sqlstore.New(ctx, "sqlite3", dsn, waLog.Noop)
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	device, err := container.GetFirstDevice(ctx)
+	container.Close()
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	if device.ID == nil {
+		return types.EmptyJID, nil
+	}
+
+	src, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	defer src.Close()
+
+	var tables []string
+	rows, err := src.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'whatsmeow_%' AND name <> 'whatsmeow_version'`)
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return types.EmptyJID, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	// As demais tabelas referenciam whatsmeow_device, e as macs referenciam
+	// whatsmeow_app_state_version.
+	first := []string{"whatsmeow_device", "whatsmeow_app_state_version"}
+	slices.SortFunc(tables, func(a, b string) int {
+		ia, ib := slices.Index(first, a), slices.Index(first, b)
+		if ia < 0 {
+			ia = len(first)
+		}
+		if ib < 0 {
+			ib = len(first)
+		}
+		if ia != ib {
+			return ia - ib
+		}
+		return strings.Compare(a, b)
+	})
+
+	tx, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		if err := copyTable(ctx, src, tx, dialect, table); err != nil {
+			return types.EmptyJID, fmt.Errorf("%s: %w", table, err)
+		}
+	}
+
+	return *device.ID, tx.Commit()
+}
+
+// snapshotStore copia o store.db para um diretório temporário. VACUUM INTO
+// inclui o que ainda está no WAL e só lê o original.
+func snapshotStore(ctx context.Context, path string) (string, error) {
+	dir, err := os.MkdirTemp("", "zaapi-import-")
+	if err != nil {
+		return "", err
+	}
+	snapshot := filepath.Join(dir, "store.db")
+
+	src, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err == nil {
+		_, err = src.ExecContext(ctx, "VACUUM INTO ?", snapshot)
+		src.Close()
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("erro ao copiar o store: %w", err)
+	}
+	return snapshot, nil
+}
+
+// copyTable copia todas as linhas da tabela, ignorando as que já existem no destino.
+func copyTable(ctx context.Context, src *sql.DB, dst *sql.Tx, dialect, table string) error {
+	rows, err := src.QueryContext(ctx, "SELECT * FROM "+table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	placeholders := make([]string, len(columns))
+	for n := range columns {
+		placeholders[n] = "?"
+		if dialect == "pgx" {
+			placeholders[n] = fmt.Sprintf("$%d", n+1)
+		}
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for n := range values {
+			ptrs[n] = &values[n]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if _, err := dst.ExecContext(ctx, insert, values...); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// setDevice associa o aparelho à instância no banco, criando a linha da
+// instância a partir do session.yml se ela ainda não existir.
+func setDevice(id string, jid types.JID) error {
+	db := database.Instance()
+
+	res := db.Model(&dbmodels.Instance{}).Where("id = ?", id).Updates(map[string]any{
+		"device_jid": jid.String(),
+		"number":     sql.NullString{String: jid.User, Valid: true},
+	})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+
+	s, err := readSessionYml(id)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		s = &InstaciaYml{Id: id, Token: newToken()}
+	}
+	s.Device = jid.String()
+	s.Number = jid.User
+
+	row, err := rowFromYml(*s)
+	if err != nil {
+		return err
+	}
+	return db.Create(&row).Error
+}
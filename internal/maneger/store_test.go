@@ -0,0 +1,75 @@
+package maneger
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// legacyStore cria um store.db em WAL com uma linha que ainda não passou do
+// WAL para o arquivo. O banco fica aberto até o fim do teste.
+func legacyStore(t *testing.T) (string, *sql.DB) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "store.db")
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		"PRAGMA wal_autocheckpoint = 0",
+		"CREATE TABLE legado (x integer)",
+		"INSERT INTO legado VALUES (1)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	return path, db
+}
+
+func TestSnapshotStoreIncludesWAL(t *testing.T) {
+	path, _ := legacyStore(t)
+
+	snapshot, err := snapshotStore(context.Background(), path)
+	if err != nil {
+		t.Fatalf("snapshotStore: %v", err)
+	}
+	defer os.RemoveAll(filepath.Dir(snapshot))
+
+	db, err := sql.Open("sqlite3", "file:"+snapshot)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM legado").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("cópia com %d linhas (%v), esperado 1", n, err)
+	}
+}
+
+// TestImportStoreLeavesOriginalUntouched garante que o sqlstore atualiza o
+// esquema da cópia, e não o store.db da sessão.
+func TestImportStoreLeavesOriginalUntouched(t *testing.T) {
+	path, db := legacyStore(t)
+
+	jid, err := importStore(context.Background(), path, nil, "sqlite3")
+	if err != nil {
+		t.Fatalf("importStore: %v", err)
+	}
+	if !jid.IsEmpty() {
+		t.Fatalf("aparelho %s importado de um store sem pareamento", jid)
+	}
+
+	var n int
+	err = db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name LIKE 'whatsmeow_%'").Scan(&n)
+	if err != nil || n != 0 {
+		t.Fatalf("store.db original com %d tabelas do whatsmeow (%v), esperado 0", n, err)
+	}
+}
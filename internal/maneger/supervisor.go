@@ -0,0 +1,167 @@
+package maneger
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/models"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Valores usados quando o config.yml não define a seção reconnect.
+const (
+	defaultBaseDelay = 2 * time.Second
+	defaultMaxDelay  = 5 * time.Minute
+	defaultKeepAlive = 3 * time.Minute
+)
+
+// startSupervisor inicia as goroutines da instância em execução: a que
+// reconecta a instância e a da fila de envio. Quem chama deve segurar i.Mu.
+func (i *Instancia) startSupervisor() {
+	if i.superviseStop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i.superviseStop = cancel
+	i.wakeup = make(chan struct{}, 1)
+	i.reconnectAttempts.Store(0)
+	i.ReconnectFailed.Store(false)
+
+	go i.supervise(ctx, i.wakeup)
+	go i.runQueue(ctx)
+}
+
+// stopSupervisor cancela a reconexão em andamento e pausa a fila de envio.
+// Quem chama deve segurar i.Mu.
+func (i *Instancia) stopSupervisor() {
+	if i.superviseStop == nil {
+		return
+	}
+
+	i.superviseStop()
+	i.superviseStop = nil
+}
+
+// reconnect pede ao supervisor uma nova conexão, sem bloquear.
+func (i *Instancia) reconnect() {
+	i.Mu.RLock()
+	wakeup := i.wakeup
+	i.Mu.RUnlock()
+
+	if wakeup == nil {
+		return
+	}
+
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// supervise aguarda quedas de conexão e reconecta com backoff exponencial e
+// jitter. Desiste após reconnect.max_attempts falhas seguidas e não tenta em
+// condições permanentes (logout, ban ou sessão não pareada).
+//
+// As falhas ficam em i.reconnectAttempts e só voltam a zero com o evento
+// Connected: abrir o socket não basta, a conexão pode cair antes do login.
+func (i *Instancia) supervise(ctx context.Context, wakeup <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wakeup:
+		}
+
+		cfg := config.Get().Reconnect
+
+		for {
+			if !i.shouldReconnect() {
+				break
+			}
+
+			attempt := int(i.reconnectAttempts.Add(1))
+			if cfg.MaxAttempts > 0 && attempt > cfg.MaxAttempts {
+				i.ReconnectFailed.Store(true)
+				i.setState(models.StateDisconnected, "reconexão abandonada após sucessivas falhas")
+				log.Errorf("Instância %s: desistindo de reconectar após %d tentativas", i.Id, cfg.MaxAttempts)
+				break
+			}
+
+			wait := reconnectDelay(cfg, attempt)
+			log.Infof("Instância %s: reconectando em %s (tentativa %d)", i.Id, wait.Round(time.Millisecond), attempt)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			if !i.shouldReconnect() {
+				break
+			}
+
+			i.setState(models.StateConnecting, "reconectando")
+			if err := i.Client().Connect(); err != nil {
+				log.Warnf("Instância %s: falha ao reconectar: %v", i.Id, err)
+				continue
+			}
+
+			break
+		}
+	}
+}
+
+// shouldReconnect informa se ainda faz sentido tentar reconectar.
+func (i *Instancia) shouldReconnect() bool {
+	if i.Stopped.Load() || i.Client().Store.ID == nil || i.Client().IsConnected() {
+		return false
+	}
+
+	switch state, _ := i.State(); state {
+	case models.StateLoggedOut, models.StateBanned, models.StateStopped:
+		return false
+	}
+	return true
+}
+
+// handleKeepAlive força a reconexão quando o servidor para de responder aos keepalives.
+func (i *Instancia) handleKeepAlive(e *events.KeepAliveTimeout) {
+	limit := time.Duration(config.Get().Reconnect.KeepAlive) * time.Second
+	if limit <= 0 {
+		limit = defaultKeepAlive
+	}
+
+	if time.Since(e.LastSuccess) < limit {
+		return
+	}
+
+	log.Warnf("Instância %s: sem keepalive desde %s, reconectando", i.Id, e.LastSuccess.Format(time.RFC3339))
+	i.Client().Disconnect()
+	i.setState(models.StateDisconnected, "keepalive sem resposta")
+	i.reconnect()
+}
+
+// reconnectDelay calcula a espera da tentativa: base * 2^(n-1), limitada a
+// max_delay, com jitter entre metade e o valor cheio.
+func reconnectDelay(cfg config.ReconnectConfig, attempt int) time.Duration {
+	base := time.Duration(cfg.BaseDelay) * time.Second
+	if base <= 0 {
+		base = defaultBaseDelay
+	}
+
+	max := time.Duration(cfg.MaxDelay) * time.Second
+	if max <= 0 {
+		max = defaultMaxDelay
+	}
+
+	wait := base << min(attempt-1, 30)
+	if wait <= 0 || wait > max {
+		wait = max
+	}
+
+	return wait/2 + rand.N(wait/2+1)
+}
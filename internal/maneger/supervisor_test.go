@@ -0,0 +1,46 @@
+package maneger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// TestSupervisorKeepsFailuresUntilConnected garante que as falhas somam entre
+// um wakeup e outro e só voltam a zero com o evento Connected.
+func TestSupervisorKeepsFailuresUntilConnected(t *testing.T) {
+	prev := config.Get()
+	cfg := config.DefaultConfig()
+	cfg.Reconnect.MaxAttempts = 3
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(prev) })
+
+	i := newTestInstance(t)
+	i.Client().Store.ID = &types.JID{User: "5511999999999", Server: types.DefaultUserServer}
+
+	i.Mu.Lock()
+	i.Stopped.Store(false)
+	i.startSupervisor()
+	i.Mu.Unlock()
+
+	// Falhas de wakeups anteriores.
+	i.reconnectAttempts.Store(3)
+	i.reconnect()
+
+	deadline := time.Now().Add(time.Second)
+	for !i.ReconnectFailed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("o supervisor recomeçou a contagem no novo wakeup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	i.Listen.Store(true)
+	i.handleEvent(&events.Connected{})
+	if n := i.reconnectAttempts.Load(); n != 0 || i.ReconnectFailed.Load() {
+		t.Fatalf("após Connected: %d falhas, desistiu = %v", n, i.ReconnectFailed.Load())
+	}
+}
@@ -0,0 +1,30 @@
+package maneger
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// newToken gera um token aleatório de 256 bits.
+func newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// CheckToken informa se token é o token de acesso da instância.
+func (i *Instancia) CheckToken(token string) bool {
+	i.Mu.RLock()
+	defer i.Mu.RUnlock()
+	return i.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(i.Token)) == 1
+}
+
+// RotateToken gera um novo token de acesso, invalidando o anterior.
+func (i *Instancia) RotateToken() (string, error) {
+	i.Mu.Lock()
+	defer i.Mu.Unlock()
+
+	i.Token = newToken()
+	return i.Token, i.save()
+}
@@ -1 +1,7 @@
-package models
\ No newline at end of file
+package models
+
+// Contact é um contato compartilhado em uma mensagem (vCard).
+type Contact struct {
+	Name  string `json:"name"`
+	Vcard string `json:"vcard"`
+}
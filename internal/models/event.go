@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+)
+
+// EventType é o nome do evento entregue aos consumidores.
+type EventType string
+
+const (
+	EventMessage     EventType = "message"
+	EventQR          EventType = "qr"
+	EventPairSuccess EventType = "pair_success"
+	EventLoggedIn    EventType = "logged_in"
+	EventLoggedOut   EventType = "logged_out"
+	EventReceipt     EventType = "receipt"
+	EventPresence    EventType = "presence"
+	EventConnection  EventType = "connection"
+	EventQueue       EventType = "queue"
+	EventSchedule    EventType = "schedule"
+	EventStatus      EventType = "message_status"
+)
+
+// Event é o envelope comum de todos os eventos emitidos por uma instância.
+type Event struct {
+	ID        string    `json:"id"` // único por evento, para idempotência no receptor
+	Type      EventType `json:"type"`
+	Instance  string    `json:"instance"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"`
+}
+
+// Flag retorna o bit de models.WebhookEvent correspondente ao evento.
+func (e Event) Flag() dbmodels.WebhookEvent {
+	switch e.Type {
+	case EventMessage:
+		if m, ok := e.Data.(Message); ok && m.FromMe {
+			return dbmodels.MessageSender
+		}
+		return dbmodels.MessageReceived
+	case EventQR:
+		return dbmodels.EventQR
+	case EventPairSuccess:
+		return dbmodels.PairSuccess
+	case EventLoggedIn:
+		return dbmodels.EventLoggedIn
+	case EventLoggedOut:
+		return dbmodels.EventLoggedOut
+	case EventReceipt:
+		return dbmodels.EventReceipt
+	case EventPresence:
+		return dbmodels.EventPresence
+	case EventConnection:
+		return dbmodels.EventConnection
+	case EventQueue:
+		return dbmodels.EventQueue
+	case EventSchedule:
+		return dbmodels.EventSchedule
+	case EventStatus:
+		return dbmodels.EventMessageStatus
+	}
+	return 0
+}
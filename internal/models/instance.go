@@ -1 +1,17 @@
 package models
+
+import "time"
+
+// Instance resume o estado de uma instância para a API.
+type Instance struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	Number      string    `json:"number,omitempty"`
+	Status      State     `json:"status"`
+	StatusSince time.Time `json:"status_since"`
+	Connected   bool      `json:"connected"`
+	LoggedIn    bool      `json:"logged_in"`
+	// ReconnectFailed indica que a reconexão automática desistiu após sucessivas falhas.
+	ReconnectFailed bool      `json:"reconnect_failed,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
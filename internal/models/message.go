@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// MessageType identifica o conteúdo de uma mensagem normalizada.
+type MessageType string
+
+const (
+	MessageText     MessageType = "text"
+	MessageImage    MessageType = "image"
+	MessageVideo    MessageType = "video"
+	MessageAudio    MessageType = "audio"
+	MessageDocument MessageType = "document"
+	MessageSticker  MessageType = "sticker"
+	MessageLocation MessageType = "location"
+	MessageContact  MessageType = "contact"
+	MessageReaction MessageType = "reaction"
+	MessagePoll     MessageType = "poll"
+	MessageEdit     MessageType = "edit"
+	MessageRevoke   MessageType = "revoke"
+	MessageUnknown  MessageType = "unknown"
+)
+
+// Message é o formato único de mensagem entregue a webhooks, websockets e armazenamento.
+type Message struct {
+	ID        string      `json:"id"`
+	Instance  string      `json:"instance"`
+	Type      MessageType `json:"type"`
+	Chat      Chat        `json:"chat"`
+	Sender    Sender      `json:"sender"`
+	FromMe    bool        `json:"fromMe"`
+	Timestamp time.Time   `json:"timestamp"`
+
+	Text     string    `json:"text,omitempty"`
+	Media    *Media    `json:"media,omitempty"`
+	Location *Location `json:"location,omitempty"`
+	Contacts []Contact `json:"contacts,omitempty"`
+	Reaction *Reaction `json:"reaction,omitempty"`
+	Poll     *Poll     `json:"poll,omitempty"`
+
+	// TargetID é a mensagem afetada por uma edição ou exclusão.
+	TargetID string   `json:"targetId,omitempty"`
+	Quoted   *Quoted  `json:"quoted,omitempty"`
+	Mentions []string `json:"mentions,omitempty"`
+
+	Forwarded bool `json:"forwarded,omitempty"`
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	ViewOnce  bool `json:"viewOnce,omitempty"`
+}
+
+// Chat é a conversa onde a mensagem foi enviada.
+type Chat struct {
+	JID     string `json:"jid"`
+	Number  string `json:"phone"`
+	IsGroup bool   `json:"isGroup"`
+}
+
+// Sender é quem enviou a mensagem. Em grupos é o participante.
+type Sender struct {
+	JID    string `json:"jid"`
+	Number string `json:"phone"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Media são os metadados de um anexo recebido.
+type Media struct {
+	Mimetype string `json:"mimetype"`
+	Filename string `json:"filename,omitempty"`
+	Size     uint64 `json:"size,omitempty"`
+	Seconds  uint32 `json:"seconds,omitempty"`
+	PTT      bool   `json:"ptt,omitempty"`
+	Sha256   string `json:"sha256,omitempty"`
+	// File é o caminho local da mídia, quando media.download está habilitado.
+	File string `json:"file,omitempty"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	Live      bool    `json:"live,omitempty"`
+}
+
+type Reaction struct {
+	Emoji    string `json:"emoji"` // vazio quando a reação foi removida
+	TargetID string `json:"targetId"`
+}
+
+type Poll struct {
+	Name       string   `json:"name"`
+	Options    []string `json:"options"`
+	Selectable uint32   `json:"selectable"`
+}
+
+// Quoted é a mensagem respondida.
+type Quoted struct {
+	ID     string `json:"id"`
+	Sender string `json:"sender,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Receipt é uma confirmação de entrega, leitura ou reprodução de mensagens.
+type Receipt struct {
+	Chat       string    `json:"chat"`
+	Sender     string    `json:"sender"`
+	MessageIDs []string  `json:"messageIds"`
+	Type       string    `json:"type"` // delivered, read, played...
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Presence é uma atualização de presença de um contato (online, digitando...).
+type Presence struct {
+	From     string     `json:"from"`
+	Chat     string     `json:"chat,omitempty"`
+	State    string     `json:"state"` // available, unavailable, composing, recording, paused
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+}
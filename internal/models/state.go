@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// State é o estado da conexão de uma instância.
+type State string
+
+const (
+	StateCreated      State = "created"      // criada, ainda não iniciada
+	StateAwaitingQR   State = "awaiting_qr"  // aguardando a leitura do QR code
+	StatePairing      State = "pairing"      // aguardando o código de pareamento por telefone
+	StateConnecting   State = "connecting"   // pareada, conectando ao WhatsApp
+	StateConnected    State = "connected"    // conectada e autenticada
+	StateDisconnected State = "disconnected" // conexão perdida ou login expirado
+	StateLoggedOut    State = "logged_out"   // aparelho desconectado da conta
+	StateBanned       State = "banned"       // conta banida temporariamente
+	StateStopped      State = "stopped"      // parada pela API
+)
+
+// Transition registra uma mudança de estado da instância. É o corpo do evento "connection".
+type Transition struct {
+	State     State     `json:"state"`
+	Previous  State     `json:"previous"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Situações de uma mensagem enviada, na ordem em que avançam.
+const (
+	StatusPending   = "pending"    // enviada ao servidor, sem confirmação
+	StatusServerAck = "server_ack" // aceita pelo servidor do WhatsApp
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+	StatusPlayed    = "played" // áudio ou vídeo reproduzido
+)
+
+var statusOrder = []string{StatusPending, StatusServerAck, StatusDelivered, StatusRead, StatusPlayed}
+
+// StatusAdvances informa se to vem depois de from.
+func StatusAdvances(from, to string) bool {
+	return slices.Index(statusOrder, to) > slices.Index(statusOrder, from)
+}
+
+// StatusChange é uma mudança de situação de uma mensagem enviada. Em grupos,
+// Participant indica quem confirmou.
+type StatusChange struct {
+	MessageID   string    `json:"message_id"`
+	Chat        string    `json:"chat"`
+	Participant string    `json:"participant,omitempty"`
+	Status      string    `json:"status"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// MessageStatus é a situação de uma mensagem enviada. Status é a mais avançada
+// entre os participantes; Participants traz a de cada um.
+type MessageStatus struct {
+	ID           string            `json:"id"`
+	Chat         string            `json:"chat"`
+	Status       string            `json:"status"`
+	Participants map[string]string `json:"participants,omitempty"`
+	Timeline     []StatusChange    `json:"timeline"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// Apply registra a mudança se ela avança a situação do participante (ou da
+// mensagem, quando não há participante). Retorna false se foi ignorada.
+func (s *MessageStatus) Apply(c StatusChange) bool {
+	if c.Participant != "" {
+		if !StatusAdvances(s.Participants[c.Participant], c.Status) {
+			return false
+		}
+		if s.Participants == nil {
+			s.Participants = make(map[string]string)
+		}
+		s.Participants[c.Participant] = c.Status
+	} else if !StatusAdvances(s.Status, c.Status) {
+		return false
+	}
+
+	if StatusAdvances(s.Status, c.Status) {
+		s.Status = c.Status
+	}
+	s.Timeline = append(s.Timeline, c)
+	s.UpdatedAt = c.Timestamp
+	return true
+}
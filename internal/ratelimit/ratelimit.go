@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/config"
+)
+
+// Error indica que o limite foi atingido. RetryAfter é a espera até o próximo envio liberado.
+type Error struct {
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("limite de envio atingido, tente novamente em %ds", e.Seconds())
+}
+
+// Seconds é RetryAfter arredondado para cima, como no header Retry-After.
+func (e *Error) Seconds() int {
+	return max(int(math.Ceil(e.RetryAfter.Seconds())), 1)
+}
+
+// bucket é um token bucket com capacidade para uma janela inteira, reabastecido
+// continuamente ao longo da janela.
+type bucket struct {
+	capacity float64
+	rate     float64 // tokens por segundo
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(limit int, window time.Duration, now time.Time) *bucket {
+	return &bucket{
+		capacity: float64(limit),
+		rate:     float64(limit) / window.Seconds(),
+		tokens:   float64(limit),
+		last:     now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait retorna quanto falta para haver um token disponível.
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Limiter aplica os limites por segundo, minuto e dia ao mesmo tempo. Um
+// Limiter nil não limita nada.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets []*bucket
+}
+
+// New cria o limiter. Retorna nil se nenhum limite estiver definido.
+func New(l config.Limits) *Limiter {
+	now := time.Now()
+	lim := &Limiter{}
+
+	windows := []struct {
+		limit  int
+		window time.Duration
+	}{
+		{l.PerSecond, time.Second},
+		{l.PerMinute, time.Minute},
+		{l.PerDay, 24 * time.Hour},
+	}
+	for _, w := range windows {
+		if w.limit > 0 {
+			lim.buckets = append(lim.buckets, newBucket(w.limit, w.window, now))
+		}
+	}
+
+	if len(lim.buckets) == 0 {
+		return nil
+	}
+	return lim
+}
+
+// Check informa se há um envio disponível, sem consumi-lo.
+func (l *Limiter) Check() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.check(time.Now())
+}
+
+// Allow consome um envio de todas as janelas, ou de nenhuma se alguma estiver esgotada.
+func (l *Limiter) Allow() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.check(time.Now()); err != nil {
+		return err
+	}
+
+	for _, b := range l.buckets {
+		b.tokens--
+	}
+	return nil
+}
+
+func (l *Limiter) check(now time.Time) error {
+	var wait time.Duration
+	for _, b := range l.buckets {
+		b.refill(now)
+		wait = max(wait, b.wait())
+	}
+
+	if wait > 0 {
+		return &Error{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Registry guarda um limiter por chave (por exemplo, o id da chave de API).
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+// Get retorna o limiter de key, criando-o com os limites informados.
+func (r *Registry) Get(key string, l config.Limits) *Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lim, ok := r.limiters[key]
+	if !ok {
+		lim = New(l)
+		r.limiters[key] = lim
+	}
+	return lim
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/config"
+)
+
+func TestNewWithoutLimits(t *testing.T) {
+	l := New(config.Limits{})
+	if l != nil {
+		t.Fatal("New sem limites deveria retornar nil")
+	}
+
+	for n := 0; n < 100; n++ {
+		if err := l.Allow(); err != nil {
+			t.Fatalf("limiter nil recusou o envio %d: %v", n, err)
+		}
+	}
+}
+
+func TestAllowStopsAtLimit(t *testing.T) {
+	l := New(config.Limits{PerMinute: 3})
+
+	for n := 0; n < 3; n++ {
+		if err := l.Allow(); err != nil {
+			t.Fatalf("envio %d recusado: %v", n, err)
+		}
+	}
+
+	var limited *Error
+	if err := l.Allow(); !errors.As(err, &limited) {
+		t.Fatalf("Allow acima do limite = %v, esperado *Error", err)
+	}
+	// Um token volta a cada 20s.
+	if limited.RetryAfter <= 0 || limited.RetryAfter > 20*time.Second {
+		t.Fatalf("RetryAfter = %s, esperado até 20s", limited.RetryAfter)
+	}
+}
+
+func TestCheckDoesNotConsume(t *testing.T) {
+	l := New(config.Limits{PerMinute: 1})
+
+	for n := 0; n < 3; n++ {
+		if err := l.Check(); err != nil {
+			t.Fatalf("Check %d: %v", n, err)
+		}
+	}
+	if err := l.Allow(); err != nil {
+		t.Fatalf("Allow após Check: %v", err)
+	}
+	if err := l.Check(); err == nil {
+		t.Fatal("Check não acusou o limite esgotado")
+	}
+}
+
+// TestAllowIsAllOrNothing garante que um envio recusado por uma janela não
+// consome as outras.
+func TestAllowIsAllOrNothing(t *testing.T) {
+	l := New(config.Limits{PerSecond: 10, PerMinute: 2})
+
+	for n := 0; n < 2; n++ {
+		if err := l.Allow(); err != nil {
+			t.Fatalf("envio %d recusado: %v", n, err)
+		}
+	}
+	for n := 0; n < 5; n++ {
+		if err := l.Allow(); err == nil {
+			t.Fatal("Allow acima do limite por minuto")
+		}
+	}
+
+	if tokens := l.buckets[0].tokens; tokens < 8 {
+		t.Fatalf("janela por segundo com %.1f tokens, esperado ao menos 8", tokens)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	now := time.Now()
+	b := newBucket(60, time.Minute, now)
+	b.tokens = 0
+
+	b.refill(now.Add(1500 * time.Millisecond))
+	if b.tokens < 1.49 || b.tokens > 1.51 {
+		t.Fatalf("tokens após 1,5s = %.2f, esperado 1,5", b.tokens)
+	}
+
+	b.refill(now.Add(time.Hour))
+	if b.tokens != 60 {
+		t.Fatalf("tokens = %.2f, esperado a capacidade 60", b.tokens)
+	}
+}
+
+func TestErrorSeconds(t *testing.T) {
+	tests := []struct {
+		retry time.Duration
+		want  int
+	}{
+		{0, 1},
+		{200 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1001 * time.Millisecond, 2},
+		{90 * time.Second, 90},
+	}
+
+	for _, tt := range tests {
+		if got := (&Error{RetryAfter: tt.retry}).Seconds(); got != tt.want {
+			t.Errorf("Seconds(%s) = %d, esperado %d", tt.retry, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryKeepsLimiterPerKey(t *testing.T) {
+	r := NewRegistry()
+	limits := config.Limits{PerMinute: 1}
+
+	if err := r.Get("a", limits).Allow(); err != nil {
+		t.Fatalf("Allow a: %v", err)
+	}
+	if err := r.Get("a", limits).Allow(); err == nil {
+		t.Fatal("o registro criou outro limiter para a mesma chave")
+	}
+	if err := r.Get("b", limits).Allow(); err != nil {
+		t.Fatalf("Allow b: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/apikey"
+	"github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name"`
+	Instance   string     `json:"instance"` // vazio vale para todas as instâncias
+	Scopes     []string   `json:"scopes" binding:"required"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// apiKeyResponse omite o hash da chave.
+func apiKeyResponse(k models.APIKey) gin.H {
+	res := gin.H{
+		"id":           k.ID,
+		"name":         k.Name,
+		"prefix":       k.Prefix,
+		"instance":     k.InstanceID,
+		"scopes":       strings.Split(k.Scopes, ","),
+		"allowed_ips":  []string{},
+		"expires_at":   k.ExpiresAt,
+		"last_used_at": k.LastUsedAt,
+		"last_used_ip": k.LastUsedIP,
+		"created_at":   k.CreatedAt,
+	}
+	if k.AllowedIPs != "" {
+		res["allowed_ips"] = strings.Split(k.AllowedIPs, ",")
+	}
+	return res
+}
+
+// CreateAPIKey emite uma chave com escopos. A chave só aparece nesta resposta.
+func CreateAPIKey(ctx *gin.Context) {
+	if !requireDatabase(ctx) {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	if req.Instance != "" {
+		if _, ok := getInstanceByID(ctx, req.Instance); !ok {
+			return
+		}
+	}
+
+	key, row, err := apikey.Create(apikey.Options{
+		Name:       req.Name,
+		InstanceID: req.Instance,
+		Scopes:     req.Scopes,
+		AllowedIPs: req.AllowedIPs,
+		ExpiresAt:  req.ExpiresAt,
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	res := apiKeyResponse(*row)
+	res["key"] = key
+	ctx.JSON(201, res)
+}
+
+// ListAPIKeys lista as chaves emitidas, sem o valor das chaves.
+func ListAPIKeys(ctx *gin.Context) {
+	if !requireDatabase(ctx) {
+		return
+	}
+
+	rows, err := apikey.List()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, apiKeyResponse(row))
+	}
+
+	ctx.JSON(200, gin.H{"keys": keys})
+}
+
+// RevokeAPIKey apaga a chave; requisições com ela passam a receber 401.
+func RevokeAPIKey(ctx *gin.Context) {
+	if !requireDatabase(ctx) {
+		return
+	}
+
+	ok, err := apikey.Revoke(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		ctx.JSON(404, gin.H{"error": "chave não encontrada"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "Chave revogada com sucesso"})
+}
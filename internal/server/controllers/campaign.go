@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateCampaignRequest cria uma campanha. Os destinatários vêm em
+// "recipients" (JSON), em "csv" ou no arquivo CSV enviado em "file".
+type CreateCampaignRequest struct {
+	Name       string           `json:"name" form:"name"`
+	Template   string           `json:"template" form:"template" binding:"required"`
+	Recipients []map[string]any `json:"recipients" form:"-"`
+	CSV        string           `json:"csv" form:"csv"`
+	QueueRequest
+}
+
+// phoneColumns são os nomes aceitos para a coluna do número.
+var phoneColumns = []string{"phone", "number", "to", "telefone", "numero"}
+
+var errNoPhoneColumn = errors.New("o CSV precisa de uma coluna phone")
+
+// CreateCampaign cria a campanha e começa a enviar.
+func CreateCampaign(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok || !requireDatabase(ctx) {
+		return
+	}
+
+	limitMediaBody(ctx)
+
+	var req CreateCampaignRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		bindError(ctx, err)
+		return
+	}
+
+	recipients, err := readRecipients(ctx, &req)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	queueOpts, err := req.options()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	c, err := instance.CreateCampaign(maneger.CampaignOptions{
+		Name:       req.Name,
+		Template:   req.Template,
+		Recipients: recipients,
+		Queue:      queueOpts,
+	})
+	if err != nil {
+		campaignError(ctx, err)
+		return
+	}
+
+	ctx.JSON(201, c)
+}
+
+// ListCampaigns lista as campanhas da instância com o andamento de cada uma.
+func ListCampaigns(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok || !requireDatabase(ctx) {
+		return
+	}
+
+	list, err := instance.Campaigns()
+	if err != nil {
+		campaignError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"campaigns": list})
+}
+
+// GetCampaign retorna a campanha e a contagem de destinatários por situação.
+func GetCampaign(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok || !requireDatabase(ctx) {
+		return
+	}
+
+	c, err := instance.Campaign(ctx.Param("id"))
+	if err != nil {
+		campaignError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, c)
+}
+
+// ListCampaignRecipients lista os destinatários da campanha. Aceita ?status=,
+// ?limit= (até 1000, padrão 100) e ?offset=.
+func ListCampaignRecipients(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok || !requireDatabase(ctx) {
+		return
+	}
+
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(ctx.Query("offset"))
+	limit = min(max(limit, 1), 1000)
+	offset = max(offset, 0)
+
+	list, total, err := instance.CampaignRecipients(ctx.Param("id"), ctx.Query("status"), limit, offset)
+	if err != nil {
+		campaignError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"recipients": list, "total": total, "limit": limit, "offset": offset})
+}
+
+// CampaignAction retorna o handler que pausa, retoma ou cancela a campanha.
+func CampaignAction(action func(*maneger.Instancia, string) (maneger.Campaign, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		instance, ok := getInstance(ctx)
+		if !ok || !requireDatabase(ctx) {
+			return
+		}
+
+		c, err := action(instance, ctx.Param("id"))
+		if err != nil {
+			campaignError(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, c)
+	}
+}
+
+func campaignError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, maneger.ErrCampaignNotFound):
+		ctx.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, maneger.ErrCampaignState):
+		ctx.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, maneger.ErrInvalidCampaign):
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+	default:
+		sendError(ctx, err)
+	}
+}
+
+// readRecipients lê os destinatários do arquivo CSV, do campo csv ou da lista JSON.
+func readRecipients(ctx *gin.Context, req *CreateCampaignRequest) ([]maneger.Recipient, error) {
+	if fh, err := ctx.FormFile("file"); err == nil {
+		if limit := maxMediaSize(); fh.Size > limit {
+			return nil, fmt.Errorf("arquivo maior que %d bytes", limit)
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return parseRecipientsCSV(f)
+	}
+
+	if req.CSV != "" {
+		return parseRecipientsCSV(strings.NewReader(req.CSV))
+	}
+
+	recipients := make([]maneger.Recipient, 0, len(req.Recipients))
+	for n, row := range req.Recipients {
+		r := maneger.Recipient{Vars: map[string]string{}}
+		for key, value := range row {
+			if r.Phone == "" && isPhoneColumn(key) {
+				r.Phone = jsonString(value)
+				continue
+			}
+			r.Vars[key] = jsonString(value)
+		}
+		if r.Phone == "" {
+			return nil, fmt.Errorf("destinatário %d sem o campo phone", n+1)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// parseRecipientsCSV lê um CSV com cabeçalho. A coluna phone é o número e as
+// demais viram variáveis do modelo.
+func parseRecipientsCSV(r io.Reader) ([]maneger.Recipient, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("CSV inválido: %w", err)
+	}
+
+	phone := -1
+	for n, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		header[n] = name
+		if phone < 0 && isPhoneColumn(name) {
+			phone = n
+		}
+	}
+	if phone < 0 {
+		return nil, errNoPhoneColumn
+	}
+
+	var recipients []maneger.Recipient
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV inválido: %w", err)
+		}
+
+		if phone >= len(record) || strings.TrimSpace(record[phone]) == "" {
+			continue
+		}
+
+		rec := maneger.Recipient{Phone: strings.TrimSpace(record[phone]), Vars: map[string]string{}}
+		for n, value := range record {
+			if n != phone && n < len(header) && header[n] != "" {
+				rec.Vars[header[n]] = value
+			}
+		}
+		recipients = append(recipients, rec)
+	}
+
+	return recipients, nil
+}
+
+// jsonString converte um valor JSON em texto sem notação científica nos números.
+func jsonString(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func isPhoneColumn(name string) bool {
+	for _, c := range phoneColumns {
+		if strings.EqualFold(name, c) {
+			return true
+		}
+	}
+	return false
+}
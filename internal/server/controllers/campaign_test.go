@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseRecipientsCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []maneger.Recipient
+		err  error
+	}{
+		{
+			"colunas",
+			"phone,nome,pedido\n5511999999999,Ana,42\n5511888888888,Bruno,43\n",
+			[]maneger.Recipient{
+				{Phone: "5511999999999", Vars: map[string]string{"nome": "Ana", "pedido": "42"}},
+				{Phone: "5511888888888", Vars: map[string]string{"nome": "Bruno", "pedido": "43"}},
+			},
+			nil,
+		},
+		{
+			"BOM e nome alternativo",
+			"\ufeffNome, Telefone\nAna, 5511999999999\n",
+			[]maneger.Recipient{{Phone: "5511999999999", Vars: map[string]string{"Nome": "Ana"}}},
+			nil,
+		},
+		{
+			"linhas sem número e colunas a mais",
+			"numero,nome\n,Ana\n5511999999999,Bruno,extra\n",
+			[]maneger.Recipient{{Phone: "5511999999999", Vars: map[string]string{"nome": "Bruno"}}},
+			nil,
+		},
+		{"sem coluna phone", "nome\nAna\n", nil, errNoPhoneColumn},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRecipientsCSV(strings.NewReader(tt.csv))
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: erro = %v, esperado %v", tt.name, err, tt.err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Fatalf("%s: destinatários = %v, esperado %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := parseRecipientsCSV(strings.NewReader("")); err == nil {
+		t.Fatal("CSV vazio aceito")
+	}
+}
+
+func TestReadRecipientsJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		recipients []map[string]any
+		want       []maneger.Recipient
+		wantErr    bool
+	}{
+		{
+			"número e variáveis",
+			[]map[string]any{{"phone": "5511999999999", "nome": "Ana", "pedido": float64(1234567890)}},
+			[]maneger.Recipient{{Phone: "5511999999999", Vars: map[string]string{"nome": "Ana", "pedido": "1234567890"}}},
+			false,
+		},
+		{
+			"número em JSON",
+			[]map[string]any{{"to": float64(5511999999999)}},
+			[]maneger.Recipient{{Phone: "5511999999999", Vars: map[string]string{}}},
+			false,
+		},
+		{"sem phone", []map[string]any{{"nome": "Ana"}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("POST", "/", nil)
+
+		got, err := readRecipients(ctx, &CreateCampaignRequest{Recipients: tt.recipients})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: erro = %v", tt.name, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Fatalf("%s: destinatários = %v, esperado %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestReadRecipientsCSVField garante que o campo csv tem prioridade sobre a lista JSON.
+func TestReadRecipientsCSVField(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("POST", "/", nil)
+
+	req := &CreateCampaignRequest{
+		CSV:        "phone\n5511999999999\n",
+		Recipients: []map[string]any{{"phone": "5511888888888"}},
+	}
+	got, err := readRecipients(ctx, req)
+	if err != nil || len(got) != 1 || got[0].Phone != "5511999999999" {
+		t.Fatalf("readRecipients = %v (%v), esperado o número do CSV", got, err)
+	}
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+)
+
+// ListChatMessages lista o histórico de uma conversa, da mensagem mais nova
+// para a mais antiga, paginado por cursor.
+func ListChatMessages(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok || !requireDatabase(ctx) {
+		return
+	}
+
+	chat, err := maneger.ParseRecipient(ctx.Param("jid"))
+	if err != nil {
+		sendError(ctx, err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	q := maneger.MessageQuery{
+		Chat:   chat,
+		Type:   ctx.Query("type"),
+		Search: ctx.Query("q"),
+		Cursor: ctx.Query("cursor"),
+		Limit:  min(max(limit, 1), 500),
+	}
+
+	if v := ctx.Query("fromMe"); v != "" {
+		fromMe, err := strconv.ParseBool(v)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "fromMe deve ser true ou false", "code": CodeInvalidBody})
+			return
+		}
+		q.FromMe = &fromMe
+	}
+
+	for param, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		v := ctx.Query(param)
+		if v == "" {
+			continue
+		}
+		if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+			ctx.JSON(400, gin.H{"error": param + " deve estar no formato RFC 3339", "code": CodeInvalidBody})
+			return
+		}
+	}
+
+	list, next, err := instance.ChatMessages(q)
+	if err != nil {
+		if errors.Is(err, maneger.ErrInvalidCursor) {
+			ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+			return
+		}
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"messages": list, "next_cursor": next})
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gedsonn/zaapi/internal/ratelimit"
+	"github.com/gedsonn/zaapi/internal/server/http/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Códigos de erro retornados no campo "code" das respostas.
+const (
+	CodeQRPending        = "ZAAPI-0001"                // QR code ainda não gerado
+	CodeNotLoggedIn      = "ZAAPI-0002"                // sessão não pareada/conectada
+	CodeNotOnWhatsApp    = "ZAAPI-0003"                // número não existe no WhatsApp
+	CodeInvalidRecipient = "ZAAPI-0004"                // número ou JID inválido
+	CodeInvalidBody      = "ZAAPI-0005"                // corpo da requisição inválido
+	CodeSessionNotFound  = "ZAAPI-0006"                // instância não encontrada
+	CodeNoDatabase       = "ZAAPI-0007"                // recurso exige banco de dados
+	CodeUnauthorized     = middleware.CodeUnauthorized // token ausente ou inválido
+	CodeForbidden        = middleware.CodeForbidden    // chave sem permissão para a rota
+	CodeRateLimited      = middleware.CodeRateLimited  // limite de envio atingido
+	CodeQueueFull        = "ZAAPI-0011"                // fila de envio da sessão cheia
+)
+
+// getInstance busca a instância de :session. Responde 404 se ela não existir.
+func getInstance(ctx *gin.Context) (*maneger.Instancia, bool) {
+	return getInstanceByID(ctx, ctx.Param("session"))
+}
+
+// getInstanceByID busca a instância pelo id. Responde 404 se ela não existir.
+func getInstanceByID(ctx *gin.Context, id string) (*maneger.Instancia, bool) {
+	m := middleware.ExtractManeger(ctx)
+
+	instance, ok := m.Get(id)
+	if !ok {
+		ctx.JSON(404, gin.H{
+			"error": "Instancia não encontrada",
+			"code":  CodeSessionNotFound,
+		})
+		return nil, false
+	}
+
+	return instance, true
+}
+
+// requireDatabase responde 503 se o banco de dados não estiver configurado.
+func requireDatabase(ctx *gin.Context) bool {
+	if database.Available() {
+		return true
+	}
+
+	ctx.JSON(503, gin.H{
+		"error": "este recurso exige um banco de dados configurado",
+		"code":  CodeNoDatabase,
+	})
+	return false
+}
+
+// bindError responde o erro da leitura do corpo: 413 se ele passou do limite,
+// 400 nos demais casos.
+func bindError(ctx *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ctx.JSON(413, gin.H{"error": fmt.Sprintf("corpo maior que %d bytes", tooLarge.Limit), "code": CodeInvalidBody})
+		return
+	}
+	ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+}
+
+// sendError converte os erros do envio em uma resposta estruturada.
+func sendError(ctx *gin.Context, err error) {
+	var (
+		limited *ratelimit.Error
+		full    *maneger.QueueFullError
+	)
+
+	switch {
+	case errors.As(err, &limited):
+		middleware.TooManyRequests(ctx, err)
+	case errors.As(err, &full):
+		ctx.Header("Retry-After", strconv.Itoa(full.Seconds()))
+		ctx.JSON(429, gin.H{"error": err.Error(), "code": CodeQueueFull})
+	case errors.Is(err, maneger.ErrNotLoggedIn):
+		ctx.JSON(409, gin.H{"error": err.Error(), "code": CodeNotLoggedIn})
+	case errors.Is(err, maneger.ErrNotOnWhatsApp):
+		ctx.JSON(404, gin.H{"error": err.Error(), "code": CodeNotOnWhatsApp})
+	case errors.Is(err, maneger.ErrInvalidRecipient):
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidRecipient})
+	case errors.Is(err, maneger.ErrEmptyMedia):
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+	default:
+		ctx.JSON(500, gin.H{"error": err.Error()})
+	}
+}
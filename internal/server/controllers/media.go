@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+type SendMediaRequest struct {
+	To        string                 `json:"to" form:"to" binding:"required"`
+	Base64    string                 `json:"base64" form:"base64"`
+	Url       string                 `json:"url" form:"url"`
+	Caption   string                 `json:"caption" form:"caption"`
+	Mimetype  string                 `json:"mimetype" form:"mimetype"`
+	Filename  string                 `json:"filename" form:"filename"`
+	PTT       bool                   `json:"ptt" form:"ptt"`
+	Thumbnail string                 `json:"thumbnail" form:"thumbnail"`
+	Quoted    *maneger.QuotedMessage `json:"quoted" form:"-"`
+	Mentions  []string               `json:"mentions" form:"mentions"`
+	QueueRequest
+}
+
+var errNoMedia = errors.New("informe o arquivo em 'file', 'base64' ou 'url'")
+
+var errBlockedAddress = errors.New("endereço não permitido")
+
+// mediaClient baixa as mídias informadas por URL. Só conecta em endereços
+// públicos, inclusive depois de redirecionamentos, para que a URL não sirva
+// de acesso à rede interna do servidor.
+var mediaClient = &http.Client{
+	Timeout: 60 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: publicOnly,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("redirecionamentos demais")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirecionamento para url inválida: %s", req.URL.Scheme)
+		}
+		return nil
+	},
+}
+
+// publicOnly recusa conexões com loopback, redes privadas, link-local e
+// endereços não roteáveis. Chamado a cada conexão, já com o IP resolvido.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := addrPort.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || cgnat.Contains(ip) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, ip)
+	}
+	return nil
+}
+
+// cgnat é a faixa compartilhada 100.64.0.0/10, também usada em redes internas.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// SendMedia retorna o handler que envia mídias do tipo informado.
+// Aceita multipart (campo "file"), base64 ou uma URL.
+func SendMedia(kind maneger.MediaKind) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		instance, ok := getInstance(ctx)
+		if !ok {
+			return
+		}
+
+		limitMediaBody(ctx)
+
+		var req SendMediaRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			bindError(ctx, err)
+			return
+		}
+
+		// O multipart não tem campos aninhados; "quoted" vem como JSON.
+		if q := ctx.PostForm("quoted"); q != "" && req.Quoted == nil {
+			if err := json.Unmarshal([]byte(q), &req.Quoted); err != nil {
+				ctx.JSON(400, gin.H{"error": "campo 'quoted' inválido", "code": CodeInvalidBody})
+				return
+			}
+		}
+
+		to, msg, media, ok := prepareMedia(ctx, instance, kind, &req)
+		if !ok {
+			return
+		}
+
+		queueOpts, err := req.options()
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+			return
+		}
+
+		item, err := instance.Enqueue(to, msg, queueOpts)
+		if err != nil {
+			sendError(ctx, err)
+			return
+		}
+
+		if config.Get().Media.SaveSent {
+			if _, err := instance.SaveMedia(item.ID, media); err != nil {
+				log.Warnf("Erro ao salvar cópia da mídia %s: %v", item.ID, err)
+			}
+		}
+
+		ctx.JSON(202, sendResponse(item))
+	}
+}
+
+// prepareMedia lê o arquivo, valida o destino e faz o upload da mídia,
+// respondendo o erro ao cliente quando algo falha.
+func prepareMedia(ctx *gin.Context, instance *maneger.Instancia, kind maneger.MediaKind, req *SendMediaRequest) (types.JID, *waE2E.Message, maneger.Media, bool) {
+	to, media, opts, ok := readMediaRequest(ctx, instance, kind, req)
+	if !ok {
+		return types.EmptyJID, nil, media, false
+	}
+
+	msg, err := instance.BuildMedia(ctx, media, opts)
+	if err != nil {
+		sendError(ctx, err)
+		return types.EmptyJID, nil, media, false
+	}
+
+	return to, msg, media, true
+}
+
+// readMediaRequest lê o arquivo, as opções e o destino, sem fazer o upload,
+// respondendo o erro ao cliente quando algo falha.
+func readMediaRequest(ctx *gin.Context, instance *maneger.Instancia, kind maneger.MediaKind, req *SendMediaRequest) (types.JID, maneger.Media, maneger.SendOptions, bool) {
+	media := maneger.Media{
+		Kind:     kind,
+		Mimetype: req.Mimetype,
+		Filename: req.Filename,
+		Caption:  req.Caption,
+		PTT:      req.PTT,
+	}
+
+	var err error
+	media.Data, err = readMedia(ctx, req, &media)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return types.EmptyJID, media, maneger.SendOptions{}, false
+	}
+
+	if req.Thumbnail != "" {
+		media.Thumbnail, err = decodeBase64(req.Thumbnail)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "thumbnail inválida", "code": CodeInvalidBody})
+			return types.EmptyJID, media, maneger.SendOptions{}, false
+		}
+	}
+
+	opts, err := parseSendOptions(req.Quoted, req.Mentions)
+	if err != nil {
+		sendError(ctx, err)
+		return types.EmptyJID, media, opts, false
+	}
+
+	to, err := instance.ResolveRecipient(ctx, req.To)
+	if err != nil {
+		sendError(ctx, err)
+		return types.EmptyJID, media, opts, false
+	}
+
+	return to, media, opts, true
+}
+
+// readMedia lê o conteúdo do arquivo a partir do multipart, do base64 ou da URL.
+func readMedia(ctx *gin.Context, req *SendMediaRequest, media *maneger.Media) ([]byte, error) {
+	limit := maxMediaSize()
+
+	if fh, err := ctx.FormFile("file"); err == nil {
+		if fh.Size > limit {
+			return nil, fmt.Errorf("arquivo maior que %d bytes", limit)
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if media.Filename == "" {
+			media.Filename = fh.Filename
+		}
+		if media.Mimetype == "" {
+			media.Mimetype = fh.Header.Get("Content-Type")
+		}
+
+		return io.ReadAll(f)
+	}
+
+	if req.Base64 != "" {
+		// Recusa pelo tamanho codificado, antes de alocar o arquivo decodificado.
+		if len(trimDataURL(req.Base64)) > base64.StdEncoding.EncodedLen(int(limit)) {
+			return nil, fmt.Errorf("arquivo maior que %d bytes", limit)
+		}
+
+		data, err := decodeBase64(req.Base64)
+		if err != nil {
+			return nil, fmt.Errorf("base64 inválido: %w", err)
+		}
+		if int64(len(data)) > limit {
+			return nil, fmt.Errorf("arquivo maior que %d bytes", limit)
+		}
+		return data, nil
+	}
+
+	if req.Url != "" {
+		return downloadMedia(ctx, req.Url, limit, media)
+	}
+
+	return nil, errNoMedia
+}
+
+// downloadMedia baixa o arquivo de uma URL http(s) respeitando o limite de tamanho.
+func downloadMedia(ctx *gin.Context, url string, limit int64, media *maneger.Media) ([]byte, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("url inválida")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := mediaClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao baixar mídia: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("erro ao baixar mídia: status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("arquivo maior que %d bytes", limit)
+	}
+
+	if media.Mimetype == "" {
+		media.Mimetype = res.Header.Get("Content-Type")
+	}
+
+	return data, nil
+}
+
+// decodeBase64 aceita base64 puro ou no formato data URI (data:<mime>;base64,...).
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(trimDataURL(s))
+}
+
+// trimDataURL remove o prefixo "data:<mimetype>;base64," de um data URL.
+func trimDataURL(s string) string {
+	if strings.HasPrefix(s, "data:") {
+		if _, data, ok := strings.Cut(s, ","); ok {
+			return data
+		}
+	}
+	return s
+}
+
+// mediaBodyOverhead é a folga para os demais campos e o envelope do multipart.
+const mediaBodyOverhead = 1 << 20
+
+// limitMediaBody limita o corpo da requisição ao maior arquivo aceito em
+// base64, que ocupa 4/3 do tamanho original, mais os demais campos.
+func limitMediaBody(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxMediaSize()*4/3+mediaBodyOverhead)
+}
+
+// maxMediaSize retorna o tamanho máximo aceito para mídias, em bytes.
+func maxMediaSize() int64 {
+	size := config.Get().Media.MaxSize
+	if size <= 0 {
+		size = 64
+	}
+	return int64(size) << 20
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+)
+
+// withMaxMediaSize limita as mídias a mb megabytes apenas durante o teste.
+func withMaxMediaSize(t *testing.T, mb int) {
+	t.Helper()
+
+	prev := config.Get()
+	cfg := config.DefaultConfig()
+	cfg.Media.MaxSize = mb
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(prev) })
+}
+
+func TestLimitMediaBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withMaxMediaSize(t, 1)
+
+	r := gin.New()
+	r.POST("/", func(ctx *gin.Context) {
+		limitMediaBody(ctx)
+
+		var req SendMediaRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			bindError(ctx, err)
+			return
+		}
+		ctx.Status(204)
+	})
+
+	// Acima de 4/3 do limite mais a folga dos demais campos.
+	big := strings.Repeat("A", 3<<20)
+
+	var form bytes.Buffer
+	w := multipart.NewWriter(&form)
+	w.WriteField("to", "5511999999999")
+	fw, _ := w.CreateFormFile("file", "grande.bin")
+	fw.Write([]byte(big))
+	w.Close()
+
+	tests := []struct {
+		name, contentType, body string
+		want                    int
+	}{
+		{"json no limite", "application/json", `{"to":"5511999999999","base64":"` + strings.Repeat("A", 1<<20) + `"}`, 204},
+		{"json grande", "application/json", `{"to":"5511999999999","base64":"` + big + `"}`, 413},
+		{"multipart grande", w.FormDataContentType(), form.String(), 413},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, esperado %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestReadMediaBase64Limit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withMaxMediaSize(t, 1)
+
+	limit := 1 << 20
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"no limite", base64.StdEncoding.EncodeToString(make([]byte, limit)), false},
+		{"data URL no limite", "data:image/png;base64," + base64.StdEncoding.EncodeToString(make([]byte, limit)), false},
+		{"um byte a mais", base64.StdEncoding.EncodeToString(make([]byte, limit+1)), true},
+		{"texto longo inválido", strings.Repeat("!", 2*limit), true},
+	}
+
+	for _, tt := range tests {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("POST", "/", nil)
+
+		data, err := readMedia(ctx, &SendMediaRequest{Base64: tt.data}, &maneger.Media{})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: erro = %v", tt.name, err)
+		}
+		if err == nil && len(data) != limit {
+			t.Errorf("%s: %d bytes, esperado %d", tt.name, len(data), limit)
+		}
+	}
+}
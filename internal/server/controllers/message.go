@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+type SendTextRequest struct {
+	To       string                 `json:"to" binding:"required"`
+	Text     string                 `json:"text" binding:"required"`
+	Quoted   *maneger.QuotedMessage `json:"quoted"`
+	Mentions []string               `json:"mentions"`
+	QueueRequest
+}
+
+func SendText(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	var req SendTextRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	to, msg, ok := prepareText(ctx, instance, &req)
+	if !ok {
+		return
+	}
+
+	queueOpts, err := req.options()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	item, err := instance.Enqueue(to, msg, queueOpts)
+	if err != nil {
+		sendError(ctx, err)
+		return
+	}
+
+	ctx.JSON(202, sendResponse(item))
+}
+
+// prepareText valida o destino e monta a mensagem de texto, respondendo o
+// erro ao cliente quando algo falha.
+func prepareText(ctx *gin.Context, instance *maneger.Instancia, req *SendTextRequest) (types.JID, *waE2E.Message, bool) {
+	opts, err := parseSendOptions(req.Quoted, req.Mentions)
+	if err != nil {
+		sendError(ctx, err)
+		return types.EmptyJID, nil, false
+	}
+
+	to, err := instance.ResolveRecipient(ctx, req.To)
+	if err != nil {
+		sendError(ctx, err)
+		return types.EmptyJID, nil, false
+	}
+
+	return to, maneger.BuildText(req.Text, opts), true
+}
+
+// parseSendOptions valida a mensagem citada e as menções enviadas pelo cliente.
+func parseSendOptions(quoted *maneger.QuotedMessage, mentions []string) (maneger.SendOptions, error) {
+	opts := maneger.SendOptions{Quoted: quoted}
+	if quoted != nil && quoted.Id == "" {
+		opts.Quoted = nil
+	}
+
+	for _, m := range mentions {
+		jid, err := maneger.ParseRecipient(m)
+		if err != nil {
+			return opts, err
+		}
+		opts.Mentions = append(opts.Mentions, jid)
+	}
+
+	return opts, nil
+}
+
+// GetMessageStatus retorna a situação de uma mensagem enviada e o histórico
+// de confirmações, com a situação de cada participante em grupos.
+func GetMessageStatus(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	status, ok := instance.MessageStatus(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(404, gin.H{"error": "mensagem não encontrada"})
+		return
+	}
+
+	ctx.JSON(200, status)
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+)
+
+// QueueRequest são os campos da fila aceitos por todos os envios.
+type QueueRequest struct {
+	Priority string `json:"priority" form:"priority"` // high, normal (padrão) ou low
+	Typing   *bool  `json:"typing" form:"typing"`     // nil usa queue.typing do config.yml
+}
+
+func (r QueueRequest) options() (maneger.QueueOptions, error) {
+	priority, err := maneger.ParsePriority(r.Priority)
+	return maneger.QueueOptions{Priority: priority, Typing: r.Typing}, err
+}
+
+// SendResponse é a resposta dos envios: a mensagem enfileirada mais os campos
+// id, to e timestamp (Unix, horário em que entrou na fila) que os envios
+// retornavam antes da fila.
+type SendResponse struct {
+	maneger.QueueItem
+	Timestamp int64 `json:"timestamp"`
+}
+
+func sendResponse(item maneger.QueueItem) SendResponse {
+	return SendResponse{QueueItem: item, Timestamp: item.CreatedAt.Unix()}
+}
+
+// ListQueue lista as mensagens aguardando envio, na ordem em que vão sair.
+func ListQueue(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(200, gin.H{"queue": instance.PendingQueue()})
+}
+
+// GetQueued retorna a situação de uma mensagem enviada pela fila.
+func GetQueued(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	item, ok := instance.Queued(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(404, gin.H{"error": "mensagem não encontrada na fila"})
+		return
+	}
+
+	ctx.JSON(200, item)
+}
+
+// CancelQueued retira da fila uma mensagem que ainda não foi enviada.
+func CancelQueued(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	if !instance.CancelQueued(ctx.Param("id")) {
+		ctx.JSON(409, gin.H{"error": "mensagem não está aguardando envio"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "Envio cancelado"})
+}
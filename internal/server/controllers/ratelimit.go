@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// SetRateLimitRequest sobrescreve os limites da instância. Limits nil volta aos limites globais.
+type SetRateLimitRequest struct {
+	Limits *config.Limits `json:"limits"`
+}
+
+// GetRateLimit retorna os limites de envio em vigor na instância.
+func GetRateLimit(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	limits, override := instance.Limits()
+	ctx.JSON(200, gin.H{
+		"limits":   limits,
+		"override": override,
+	})
+}
+
+// SetRateLimit altera os limites de envio da instância.
+func SetRateLimit(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	var req SetRateLimitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	if l := req.Limits; l != nil && (l.PerSecond < 0 || l.PerMinute < 0 || l.PerDay < 0) {
+		ctx.JSON(400, gin.H{"error": "os limites não podem ser negativos", "code": CodeInvalidBody})
+		return
+	}
+
+	if err := instance.SetRateLimit(req.Limits); err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	limits, override := instance.Limits()
+	ctx.JSON(200, gin.H{
+		"limits":   limits,
+		"override": override,
+	})
+}
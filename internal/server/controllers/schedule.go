@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ScheduleMessageRequest aceita os mesmos campos dos envios de texto e mídia,
+// mais o horário (send_at) ou a recorrência (cron e timezone).
+type ScheduleMessageRequest struct {
+	Type     string     `json:"type" form:"type"` // text (padrão), image, video, audio, document ou sticker
+	Text     string     `json:"text" form:"text"`
+	SendAt   *time.Time `json:"send_at" form:"send_at"`
+	Cron     string     `json:"cron" form:"cron"`
+	Timezone string     `json:"timezone" form:"timezone"`
+	SendMediaRequest
+}
+
+var mediaKinds = map[string]maneger.MediaKind{
+	"image":    maneger.MediaImage,
+	"video":    maneger.MediaVideo,
+	"audio":    maneger.MediaAudio,
+	"document": maneger.MediaDocument,
+	"sticker":  maneger.MediaSticker,
+}
+
+// ScheduleMessage agenda uma mensagem. As mídias ficam guardadas na sessão e
+// são enviadas ao WhatsApp a cada disparo, antes de a mensagem entrar na fila.
+func ScheduleMessage(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	limitMediaBody(ctx)
+
+	var req ScheduleMessageRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		bindError(ctx, err)
+		return
+	}
+
+	if q := ctx.PostForm("quoted"); q != "" && req.Quoted == nil {
+		if err := json.Unmarshal([]byte(q), &req.Quoted); err != nil {
+			ctx.JSON(400, gin.H{"error": "campo 'quoted' inválido", "code": CodeInvalidBody})
+			return
+		}
+	}
+
+	if req.Cron == "" && req.SendAt == nil {
+		ctx.JSON(400, gin.H{"error": maneger.ErrInvalidSchedule.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	queueOpts, err := req.options()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	var (
+		sc    maneger.Schedule
+		media maneger.Media
+	)
+
+	switch kind, isMedia := mediaKinds[req.Type]; {
+	case req.Type == "" || req.Type == "text":
+		if req.Text == "" {
+			ctx.JSON(400, gin.H{"error": "informe o campo 'text'", "code": CodeInvalidBody})
+			return
+		}
+		text := SendTextRequest{To: req.To, Text: req.Text, Quoted: req.Quoted, Mentions: req.Mentions}
+		to, msg, ok := prepareText(ctx, instance, &text)
+		if !ok {
+			return
+		}
+		sc, err = instance.Schedule(to, msg, req.SendAt, req.Cron, req.Timezone, queueOpts)
+	case isMedia:
+		var (
+			to   types.JID
+			opts maneger.SendOptions
+		)
+		if to, media, opts, ok = readMediaRequest(ctx, instance, kind, &req.SendMediaRequest); !ok {
+			return
+		}
+		sc, err = instance.ScheduleMedia(to, media, opts, req.SendAt, req.Cron, req.Timezone, queueOpts)
+	default:
+		ctx.JSON(400, gin.H{"error": "tipo de mensagem inválido: " + req.Type, "code": CodeInvalidBody})
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, maneger.ErrInvalidSchedule) || errors.Is(err, maneger.ErrInvalidCron) {
+			ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+			return
+		}
+		sendError(ctx, err)
+		return
+	}
+
+	if media.Data != nil && config.Get().Media.SaveSent {
+		if _, err := instance.SaveMedia(sc.ID, media); err != nil {
+			log.Warnf("Erro ao salvar cópia da mídia agendada %s: %v", sc.ID, err)
+		}
+	}
+
+	ctx.JSON(201, sc)
+}
+
+// ListSchedules lista as mensagens agendadas da instância.
+func ListSchedules(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(200, gin.H{"schedules": instance.Schedules()})
+}
+
+// CancelSchedule remove uma mensagem agendada.
+func CancelSchedule(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	found, err := instance.CancelSchedule(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		ctx.JSON(404, gin.H{"error": "agendamento não encontrado"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "Agendamento cancelado"})
+}
@@ -1,18 +1,37 @@
 package controllers
 
 import (
+	"errors"
+	"io"
 	"time"
 
+	"github.com/apex/log"
 	"github.com/bwmarrin/snowflake"
+	"github.com/gedsonn/zaapi/internal/database/models"
 	"github.com/gedsonn/zaapi/internal/maneger"
 	"github.com/gedsonn/zaapi/internal/server/http/middleware"
 	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow"
 )
 
+type CreateSessionRequest struct {
+	Name          string `json:"name"`
+	Webhook       string `json:"webhook"`
+	WebhookEvents int    `json:"webhook_events"`
+	WebhookSecret string `json:"webhook_secret"`
+}
+
 func CreateSession(ctx *gin.Context) {
 	node, _ := snowflake.NewNode(1)
 	m := middleware.ExtractManeger(ctx)
 
+	// O corpo é opcional.
+	var req CreateSessionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
 	id := node.Generate()
 
 	i, err := maneger.CreateInstance(id.String())
@@ -23,12 +42,22 @@ func CreateSession(ctx *gin.Context) {
 		return
 	}
 
+	i.Name = req.Name
+	i.Webhook = req.Webhook
+	i.WebhookEvents = models.WebhookEvent(req.WebhookEvents)
+	i.WebhookSecret = req.WebhookSecret
+
+	if err := i.Save(); err != nil {
+		log.Errorf("Erro ao salvar instância %s: %v", i.Id, err)
+	}
+
 	m.Add(i)
 	i.Start()
 
 	ctx.JSON(200, gin.H{
 		"message":    "Sessão criada com sucesso",
 		"session_id": i.Id,
+		"token":      i.Token,
 	})
 }
 
@@ -44,17 +73,17 @@ func SessionQRcode(ctx *gin.Context) {
 		return
 	}
 
-	if instance.Client.IsLoggedIn() {
+	if instance.Client().IsLoggedIn() {
 		ctx.JSON(409, gin.H{"error": "Esta sessão já está conectada."})
 		return
 	}
 
 	qr, err := instance.GetQR()
 	if err != nil {
-		if err.Error() == "solicitando novo QR code, tente novamente em alguns segundos" {
+		if errors.Is(err, maneger.ErrQRPending) {
 			ctx.JSON(200, gin.H{
 				"message": err.Error(),
-				"code":    "ZAAPI-0001", //0001 abrir "canal"
+				"code":    CodeQRPending,
 			})
 			return
 		}
@@ -67,10 +96,10 @@ func SessionQRcode(ctx *gin.Context) {
 	if qr == nil {
 		qr, err = instance.GetQR()
 		if err != nil {
-			if err.Error() == "solicitando novo QR code, tente novamente em alguns segundos" {
+			if errors.Is(err, maneger.ErrQRPending) {
 				ctx.JSON(200, gin.H{
 					"message": err.Error(),
-					"code":    "ZAAPI-0001", //0001 abrir "canal"
+					"code":    CodeQRPending,
 				})
 				return
 			}
@@ -89,3 +118,243 @@ func SessionQRcode(ctx *gin.Context) {
 		"expires_in": expiresIn,
 	})
 }
+
+// qrStreamTimeout limita a duração do stream; o fluxo de QR do WhatsApp dura cerca de 3 minutos.
+const qrStreamTimeout = 4 * time.Minute
+
+// SessionQRStream envia por Server-Sent Events cada novo QR code assim que é
+// gerado e termina com um evento "success", "timeout" ou "error".
+func SessionQRStream(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	if instance.Client().IsLoggedIn() {
+		ctx.JSON(409, gin.H{"error": "Esta sessão já está conectada."})
+		return
+	}
+
+	if instance.Stopped.Load() {
+		ctx.JSON(409, gin.H{"error": "instância está parada"})
+		return
+	}
+
+	// O stream não inicia um fluxo de QR: o fluxo é iniciado por Start ou
+	// por GET /:session/qr. Assina antes de ler o último código para não
+	// perder o próximo.
+	h := middleware.ExtractHub(ctx)
+	sub := h.Subscribe(instance.Id, models.EventQR)
+	defer h.Unsubscribe(sub)
+
+	current := instance.CurrentQR()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	if current != nil {
+		ctx.SSEvent(maneger.QREventCode, current)
+		ctx.Writer.Flush()
+	}
+
+	timeout := time.NewTimer(qrStreamTimeout)
+	defer timeout.Stop()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+
+		case <-sub.Done():
+			return false
+
+		case <-sub.Lagged():
+			ctx.SSEvent(maneger.QREventError, gin.H{"event": maneger.QREventError, "error": "consumidor lento"})
+			return false
+
+		case <-timeout.C:
+			ctx.SSEvent(maneger.QREventTimeout, gin.H{"event": maneger.QREventTimeout})
+			return false
+
+		case evt, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+
+			qr, ok := evt.Data.(*maneger.QRCodeEvent)
+			if !ok {
+				return true
+			}
+
+			ctx.SSEvent(qr.Event, qr)
+			return qr.Event == maneger.QREventCode || qr.Event == maneger.QREventPairCode
+
+		case <-heartbeat.C:
+			// Comentário SSE para manter proxies com a conexão aberta.
+			io.WriteString(w, ": ping\n\n")
+			return true
+		}
+	})
+}
+
+type PairPhoneRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+// PairPhone gera o código de pareamento por número de telefone, alternativa ao QR code.
+func PairPhone(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	var req PairPhoneRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	code, err := instance.PairPhone(ctx, req.Phone)
+	if err != nil {
+		switch {
+		case errors.Is(err, maneger.ErrAlreadyPaired):
+			ctx.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, whatsmeow.ErrPhoneNumberTooShort), errors.Is(err, whatsmeow.ErrPhoneNumberIsNotInternational):
+			ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidRecipient})
+		default:
+			ctx.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"code":       code,
+		"expires_in": 160,
+	})
+}
+
+// ListSessions lista todas as instâncias.
+func ListSessions(ctx *gin.Context) {
+	m := middleware.ExtractManeger(ctx)
+
+	list := m.List()
+	sessions := make([]any, 0, len(list))
+	for _, i := range list {
+		sessions = append(sessions, i.Info())
+	}
+
+	ctx.JSON(200, gin.H{"sessions": sessions})
+}
+
+// GetSession retorna o estado de uma instância.
+func GetSession(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(200, instance.Info())
+}
+
+// StopSession desconecta a instância sem desparear o aparelho.
+func StopSession(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	if err := instance.Stop(); err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, instance.Info())
+}
+
+// StartSession reconecta uma instância parada.
+func StartSession(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	if !instance.Stopped.Load() {
+		ctx.JSON(409, gin.H{"error": "instância já está online"})
+		return
+	}
+
+	if err := instance.Start(); err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, instance.Info())
+}
+
+// LogoutSession desconecta o aparelho da conta do WhatsApp. A sessão continua
+// existindo e pode ser pareada novamente.
+func LogoutSession(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	if err := instance.Logout(ctx); err != nil {
+		sendError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, instance.Info())
+}
+
+// DeleteSession remove a instância e apaga todos os seus dados.
+func DeleteSession(ctx *gin.Context) {
+	m := middleware.ExtractManeger(ctx)
+
+	if _, ok := getInstance(ctx); !ok {
+		return
+	}
+
+	if err := m.Delete(ctx, ctx.Param("session")); err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "Sessão removida com sucesso"})
+}
+
+// SessionState retorna o estado atual da instância e as últimas transições.
+func SessionState(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	state, since := instance.State()
+	ctx.JSON(200, gin.H{
+		"state":   state,
+		"since":   since,
+		"history": instance.History(),
+	})
+}
+
+// RotateSessionToken gera um novo token para a instância. O anterior deixa de valer.
+func RotateSessionToken(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	token, err := instance.RotateToken()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"token": token})
+}
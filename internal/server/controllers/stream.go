@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/server/http/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	heartbeatInterval = 30 * time.Second
+	writeTimeout      = 10 * time.Second
+)
+
+// StreamCommand é a mensagem que o cliente pode enviar para trocar os eventos assinados.
+type StreamCommand struct {
+	Events *int `json:"events"`
+}
+
+// SessionStream transmite por WebSocket os eventos de uma instância.
+func SessionStream(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+	stream(ctx, instance.Id)
+}
+
+// Stream transmite por WebSocket os eventos de todas as instâncias.
+func Stream(ctx *gin.Context) {
+	stream(ctx, "")
+}
+
+// stream faz o upgrade da conexão e envia os eventos do hub como JSON, com o
+// mesmo formato dos webhooks. ?events=<máscara> filtra os eventos.
+func stream(ctx *gin.Context, instance string) {
+	h := middleware.ExtractHub(ctx)
+
+	mask, _ := strconv.Atoi(ctx.Query("events"))
+
+	// Sem padrões, o Accept recusa com 403 as origens diferentes do host.
+	conn, err := websocket.Accept(ctx.Writer, ctx.Request, &websocket.AcceptOptions{
+		OriginPatterns: config.Get().Server.AllowedOrigins,
+	})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+
+	sub := h.Subscribe(instance, models.WebhookEvent(mask))
+	defer h.Unsubscribe(sub)
+
+	// Lê os comandos do cliente; também é necessário para receber os pongs e o fechamento.
+	readCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+
+	go func() {
+		defer cancel()
+		for {
+			var cmd StreamCommand
+			if err := wsjson.Read(readCtx, conn, &cmd); err != nil {
+				return
+			}
+			if cmd.Events != nil {
+				sub.SetMask(models.WebhookEvent(*cmd.Events))
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-readCtx.Done():
+			conn.Close(websocket.StatusNormalClosure, "")
+			return
+
+		case <-sub.Done():
+			conn.Close(websocket.StatusGoingAway, "servidor desligando")
+			return
+
+		case <-sub.Lagged():
+			conn.Close(websocket.StatusPolicyViolation, "consumidor lento, eventos descartados")
+			return
+
+		case evt, ok := <-sub.Events():
+			if !ok {
+				continue // assinatura encerrada; Lagged informa o motivo
+			}
+
+			wctx, wcancel := context.WithTimeout(readCtx, writeTimeout)
+			err := wsjson.Write(wctx, conn, evt)
+			wcancel()
+			if err != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			pctx, pcancel := context.WithTimeout(readCtx, writeTimeout)
+			err := conn.Ping(pctx)
+			pcancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+type SetWebhookRequest struct {
+	Url    string  `json:"url"`
+	Events int     `json:"events"`
+	Secret *string `json:"secret"` // nil mantém o secret atual
+}
+
+func GetWebhook(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	url, mask := instance.WebhookTarget()
+	ctx.JSON(200, gin.H{
+		"url":        url,
+		"events":     int(mask),
+		"has_secret": instance.Secret() != "",
+	})
+}
+
+func SetWebhook(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok {
+		return
+	}
+
+	var req SetWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	if req.Url != "" && !strings.HasPrefix(req.Url, "http://") && !strings.HasPrefix(req.Url, "https://") {
+		ctx.JSON(400, gin.H{"error": "url inválida", "code": CodeInvalidBody})
+		return
+	}
+
+	secret := instance.Secret()
+	if req.Secret != nil {
+		secret = *req.Secret
+	}
+
+	if err := instance.SetWebhook(req.Url, models.WebhookEvent(req.Events), secret); err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"url":        req.Url,
+		"events":     req.Events,
+		"has_secret": secret != "",
+	})
+}
+
+type ReplayWebhooksRequest struct {
+	Ids []string `json:"ids"`
+}
+
+// ListFailedWebhooks lista os eventos que esgotaram as tentativas de entrega.
+func ListFailedWebhooks(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok || !requireDatabase(ctx) {
+		return
+	}
+
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	rows, total, err := webhook.Failed(instance.Id, limit, offset)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	events := make([]gin.H, len(rows))
+	for n, row := range rows {
+		events[n] = gin.H{
+			"id":         row.ID,
+			"event_id":   row.EventID,
+			"url":        row.Url,
+			"type":       row.Type,
+			"payload":    json.RawMessage(row.Payload),
+			"attempts":   row.Attempts,
+			"last_error": row.LastError,
+			"created_at": row.CreatedAt,
+		}
+	}
+
+	ctx.JSON(200, gin.H{
+		"total":  total,
+		"events": events,
+	})
+}
+
+// ReplayWebhooks devolve eventos da fila de falhas para entrega. Sem ids, reenvia todos.
+func ReplayWebhooks(ctx *gin.Context) {
+	instance, ok := getInstance(ctx)
+	if !ok || !requireDatabase(ctx) {
+		return
+	}
+
+	var req ReplayWebhooksRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(400, gin.H{"error": err.Error(), "code": CodeInvalidBody})
+		return
+	}
+
+	n, err := webhook.Replay(instance.Id, req.Ids)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"replayed": n})
+}
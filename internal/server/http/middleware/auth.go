@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gedsonn/zaapi/internal/apikey"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// Códigos de erro da autenticação.
+const (
+	CodeUnauthorized = "ZAAPI-0008" // token ausente ou inválido
+	CodeForbidden    = "ZAAPI-0009" // chave sem o escopo ou fora da lista de IPs
+)
+
+// ExtractToken lê o token do header "Authorization: Bearer <token>" ou "apikey".
+func ExtractToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(c.GetHeader("apikey"))
+}
+
+// RequireAdmin libera a rota apenas para o token global (config.Token).
+// Usado no gerenciamento das próprias chaves de API.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdmin(ExtractToken(c)) {
+			unauthorized(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope libera a rota para o token global, para o token da instância de
+// :session ou para uma chave de API com o escopo informado. Chaves presas a
+// uma instância só valem nas rotas dela.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := ExtractToken(c)
+		if isAdmin(token) {
+			c.Next()
+			return
+		}
+
+		session := c.Param("session")
+		if session != "" {
+			if instance, ok := ExtractManeger(c).Get(session); ok && instance.CheckToken(token) {
+				c.Next()
+				return
+			}
+		}
+
+		if token == "" || !database.Available() {
+			unauthorized(c)
+			return
+		}
+
+		key, err := apikey.Authenticate(token, c.ClientIP())
+		if err != nil {
+			if errors.Is(err, apikey.ErrIPNotAllowed) {
+				forbidden(c, err.Error())
+				return
+			}
+			unauthorized(c)
+			return
+		}
+
+		if !key.Allows(scope, session) {
+			forbidden(c, fmt.Sprintf("chave sem permissão %s para esta rota", scope))
+			return
+		}
+
+		c.Set("apikey", key)
+		c.Next()
+	}
+}
+
+func isAdmin(token string) bool {
+	global := config.Get().Token
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(global)) == 1
+}
+
+func unauthorized(c *gin.Context) {
+	c.AbortWithStatusJSON(401, gin.H{
+		"error": "token de acesso inválido ou ausente",
+		"code":  CodeUnauthorized,
+	})
+}
+
+func forbidden(c *gin.Context, msg string) {
+	c.AbortWithStatusJSON(403, gin.H{
+		"error": msg,
+		"code":  CodeForbidden,
+	})
+}
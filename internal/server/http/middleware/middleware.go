@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"github.com/gedsonn/zaapi/internal/hub"
 	"github.com/gedsonn/zaapi/internal/maneger"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,6 +14,13 @@ func AttachManager(m *maneger.Manager) gin.HandlerFunc {
 	}
 }
 
+func AttachHub(h *hub.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("hub", h)
+		c.Next()
+	}
+}
+
 func AttachRequestId() gin.HandlerFunc {
 	id := uuid.New().String()
 
@@ -32,3 +40,10 @@ func ExtractManeger(c *gin.Context) *maneger.Manager {
 	return v.(*maneger.Manager)
 }
 
+func ExtractHub(c *gin.Context) *hub.Hub {
+	v, ok := c.Get("hub")
+	if !ok {
+		panic("hub não configurado")
+	}
+	return v.(*hub.Hub)
+}
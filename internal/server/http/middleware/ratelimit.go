@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gedsonn/zaapi/internal/apikey"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// CodeRateLimited é o código de erro das requisições acima do limite de envio.
+const CodeRateLimited = "ZAAPI-0010"
+
+// keyLimiters guarda os limites de cada chave de API.
+var keyLimiters = ratelimit.NewRegistry()
+
+// RateLimit aplica o limite da chave de API e recusa cedo os envios quando a
+// instância já está no limite. O envio em si é contado em Instancia.Send, que
+// a fila aguarda liberar em vez de falhar a mensagem.
+func RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v, ok := c.Get("apikey"); ok {
+			key := v.(*apikey.Key)
+			if err := keyLimiters.Get(key.ID, config.Get().RateLimit.Key).Allow(); err != nil {
+				TooManyRequests(c, err)
+				return
+			}
+		}
+
+		if instance, ok := ExtractManeger(c).Get(c.Param("session")); ok {
+			if err := instance.CheckRate(); err != nil {
+				TooManyRequests(c, err)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// TooManyRequests responde 429 com Retry-After, em segundos.
+func TooManyRequests(c *gin.Context, err error) {
+	var limited *ratelimit.Error
+	if errors.As(err, &limited) {
+		c.Header("Retry-After", strconv.Itoa(limited.Seconds()))
+	}
+
+	c.AbortWithStatusJSON(429, gin.H{
+		"error": err.Error(),
+		"code":  CodeRateLimited,
+	})
+}
@@ -4,19 +4,31 @@ import (
 	"fmt"
 
 	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/apikey"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/hub"
 	"github.com/gedsonn/zaapi/internal/maneger"
 	"github.com/gedsonn/zaapi/internal/server/controllers"
 	"github.com/gedsonn/zaapi/internal/server/http/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func Configure(m *maneger.Manager) *gin.Engine {
+func Configure(m *maneger.Manager, h *hub.Hub) *gin.Engine {
 	gin.SetMode(gin.DebugMode)
 
 	router := gin.New()
+
+	// Sem proxies confiáveis, c.ClientIP() ignora X-Forwarded-For e X-Real-IP,
+	// que o cliente poderia forjar para passar pela lista de IPs das chaves.
+	if err := router.SetTrustedProxies(config.Get().Server.TrustedProxies); err != nil {
+		log.Errorf("server.trusted_proxies inválido, nenhum proxy será confiável: %v", err)
+		router.SetTrustedProxies(nil)
+	}
+
 	router.Use(gin.Recovery())
 	router.Use(middleware.AttachRequestId())
 	router.Use(middleware.AttachManager(m))
+	router.Use(middleware.AttachHub(h))
 
 	router.Use(gin.LoggerWithFormatter(func(params gin.LogFormatterParams) string {
 		requestID := ""
@@ -39,13 +51,62 @@ func Configure(m *maneger.Manager) *gin.Engine {
 	log.SetLevel(log.DebugLevel)
 
 
-	router.POST("/", controllers.CreateSession)
-	
-	session := router.Group("/:session") 
+	scope := middleware.RequireScope
+	limit := middleware.RateLimit()
+
+	router.POST("/", scope(apikey.ScopeSessionsAdmin), controllers.CreateSession)
+	router.GET("/ws", scope(apikey.ScopeMessagesRead), controllers.Stream)
+	router.GET("/sessions", scope(apikey.ScopeSessionsRead), controllers.ListSessions)
+
+	// Chaves de API só são gerenciadas com o token global.
+	keys := router.Group("/keys", middleware.RequireAdmin())
+	{
+		keys.GET("", controllers.ListAPIKeys)
+		keys.POST("", controllers.CreateAPIKey)
+		keys.DELETE("/:id", controllers.RevokeAPIKey)
+	}
+
+	session := router.Group("/:session")
 	{
-		session.GET("/qr", controllers.SessionQRcode)
+		session.GET("", scope(apikey.ScopeSessionsRead), controllers.GetSession)
+		session.GET("/state", scope(apikey.ScopeSessionsRead), controllers.SessionState)
+		session.DELETE("", scope(apikey.ScopeSessionsAdmin), controllers.DeleteSession)
+		session.POST("/start", scope(apikey.ScopeSessionsAdmin), controllers.StartSession)
+		session.POST("/stop", scope(apikey.ScopeSessionsAdmin), controllers.StopSession)
+		session.POST("/logout", scope(apikey.ScopeSessionsAdmin), controllers.LogoutSession)
+		session.POST("/token", scope(apikey.ScopeSessionsAdmin), controllers.RotateSessionToken)
+		session.GET("/ratelimit", scope(apikey.ScopeSessionsRead), controllers.GetRateLimit)
+		session.PUT("/ratelimit", scope(apikey.ScopeSessionsAdmin), controllers.SetRateLimit)
+		session.GET("/qr", scope(apikey.ScopeSessionsAdmin), controllers.SessionQRcode)
+		session.GET("/qr/stream", scope(apikey.ScopeSessionsAdmin), controllers.SessionQRStream)
+		session.POST("/pair", scope(apikey.ScopeSessionsAdmin), controllers.PairPhone)
+		session.GET("/ws", scope(apikey.ScopeMessagesRead), controllers.SessionStream)
+		session.POST("/messages/text", scope(apikey.ScopeMessagesSend), limit, controllers.SendText)
+		session.POST("/messages/image", scope(apikey.ScopeMessagesSend), limit, controllers.SendMedia(maneger.MediaImage))
+		session.POST("/messages/video", scope(apikey.ScopeMessagesSend), limit, controllers.SendMedia(maneger.MediaVideo))
+		session.POST("/messages/audio", scope(apikey.ScopeMessagesSend), limit, controllers.SendMedia(maneger.MediaAudio))
+		session.POST("/messages/document", scope(apikey.ScopeMessagesSend), limit, controllers.SendMedia(maneger.MediaDocument))
+		session.POST("/messages/sticker", scope(apikey.ScopeMessagesSend), limit, controllers.SendMedia(maneger.MediaSticker))
+		session.POST("/messages/schedule", scope(apikey.ScopeMessagesSend), limit, controllers.ScheduleMessage)
+		session.GET("/messages/schedule", scope(apikey.ScopeMessagesSend), controllers.ListSchedules)
+		session.DELETE("/messages/schedule/:id", scope(apikey.ScopeMessagesSend), controllers.CancelSchedule)
+		session.GET("/messages/:id/status", scope(apikey.ScopeMessagesRead), controllers.GetMessageStatus)
+		session.GET("/chats/:jid/messages", scope(apikey.ScopeMessagesRead), controllers.ListChatMessages)
+		session.GET("/queue", scope(apikey.ScopeMessagesSend), controllers.ListQueue)
+		session.GET("/queue/:id", scope(apikey.ScopeMessagesSend), controllers.GetQueued)
+		session.DELETE("/queue/:id", scope(apikey.ScopeMessagesSend), controllers.CancelQueued)
+		session.POST("/campaigns", scope(apikey.ScopeMessagesSend), controllers.CreateCampaign)
+		session.GET("/campaigns", scope(apikey.ScopeMessagesSend), controllers.ListCampaigns)
+		session.GET("/campaigns/:id", scope(apikey.ScopeMessagesSend), controllers.GetCampaign)
+		session.GET("/campaigns/:id/recipients", scope(apikey.ScopeMessagesSend), controllers.ListCampaignRecipients)
+		session.POST("/campaigns/:id/pause", scope(apikey.ScopeMessagesSend), controllers.CampaignAction((*maneger.Instancia).PauseCampaign))
+		session.POST("/campaigns/:id/resume", scope(apikey.ScopeMessagesSend), controllers.CampaignAction((*maneger.Instancia).ResumeCampaign))
+		session.POST("/campaigns/:id/cancel", scope(apikey.ScopeMessagesSend), controllers.CampaignAction((*maneger.Instancia).CancelCampaign))
+		session.GET("/webhook", scope(apikey.ScopeWebhooksManage), controllers.GetWebhook)
+		session.PUT("/webhook", scope(apikey.ScopeWebhooksManage), controllers.SetWebhook)
+		session.GET("/webhooks/failed", scope(apikey.ScopeWebhooksManage), controllers.ListFailedWebhooks)
+		session.POST("/webhooks/replay", scope(apikey.ScopeWebhooksManage), controllers.ReplayWebhooks)
 	}
-	
 
 	return router
 }
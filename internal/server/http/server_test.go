@@ -0,0 +1,85 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gedsonn/zaapi/internal/apikey"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/hub"
+	"github.com/gedsonn/zaapi/internal/maneger"
+)
+
+const allowedIP = "203.0.113.7"
+
+// setup abre um banco em memória e cria uma chave restrita a allowedIP.
+func setup(t *testing.T, proxies []string) (http.Handler, string) {
+	t.Helper()
+
+	prev := config.Get()
+	cfg := config.DefaultConfig()
+	cfg.Server.TrustedProxies = proxies
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(prev) })
+
+	if err := database.Open(database.Config{Driver: "sqlite", DSN: ":memory:"}); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	key, _, err := apikey.Create(apikey.Options{
+		Name:       "teste",
+		Scopes:     []string{apikey.ScopeSessionsRead},
+		AllowedIPs: []string{allowedIP},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return Configure(maneger.EmptyManager(), hub.New()), key
+}
+
+func listSessions(router http.Handler, key, remote string, headers map[string]string) int {
+	req := httptest.NewRequest("GET", "/sessions", nil)
+	req.RemoteAddr = remote + ":40000"
+	req.Header.Set("apikey", key)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestSpoofedForwardedHeaderIsIgnored(t *testing.T) {
+	router, key := setup(t, nil)
+
+	if code := listSessions(router, key, allowedIP, nil); code != 200 {
+		t.Fatalf("IP permitido: status %d, esperado 200", code)
+	}
+
+	for _, header := range []string{"X-Forwarded-For", "X-Real-IP"} {
+		code := listSessions(router, key, "198.51.100.1", map[string]string{header: allowedIP})
+		if code != 403 {
+			t.Errorf("%s forjado: status %d, esperado 403", header, code)
+		}
+	}
+}
+
+func TestTrustedProxyForwardsClientIP(t *testing.T) {
+	router, key := setup(t, []string{"10.0.0.0/8"})
+
+	if code := listSessions(router, key, "10.1.2.3", map[string]string{"X-Forwarded-For": allowedIP}); code != 200 {
+		t.Fatalf("pelo proxy confiável: status %d, esperado 200", code)
+	}
+
+	if code := listSessions(router, key, "198.51.100.1", map[string]string{"X-Forwarded-For": allowedIP}); code != 403 {
+		t.Fatalf("fora dos proxies confiáveis: status %d, esperado 403", code)
+	}
+}
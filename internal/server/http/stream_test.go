@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/hub"
+	"github.com/gedsonn/zaapi/internal/maneger"
+)
+
+// dialStream abre o WebSocket /ws com o token global e o Origin informado.
+func dialStream(t *testing.T, srv *httptest.Server, origin string) int {
+	t.Helper()
+
+	header := http.Header{"Apikey": {"admin"}}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+
+	conn, res, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", &websocket.DialOptions{HTTPHeader: header})
+	if err == nil {
+		conn.CloseNow()
+	}
+	if res == nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	return res.StatusCode
+}
+
+func TestStreamOrigins(t *testing.T) {
+	prev := config.Get()
+	t.Cleanup(func() { config.Set(prev) })
+
+	srv := httptest.NewServer(Configure(maneger.EmptyManager(), hub.New()))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    int
+	}{
+		{"sem Origin", nil, "", http.StatusSwitchingProtocols},
+		{"mesma origem", nil, srv.URL, http.StatusSwitchingProtocols},
+		{"outra origem", nil, "https://malicioso.example", http.StatusForbidden},
+		{"origem configurada", []string{"painel.example"}, "https://painel.example", http.StatusSwitchingProtocols},
+		{"padrão configurado", []string{"*.example"}, "https://app.example", http.StatusSwitchingProtocols},
+		{"fora do configurado", []string{"painel.example"}, "https://malicioso.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.Token = "admin"
+		cfg.Server.AllowedOrigins = tt.allowed
+		config.Set(cfg)
+
+		if got := dialStream(t, srv, tt.origin); got != tt.want {
+			t.Errorf("%s: status %d, esperado %d", tt.name, got, tt.want)
+		}
+	}
+}
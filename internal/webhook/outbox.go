@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lease é o tempo que uma entrega fica reservada para um worker antes de
+// voltar a ser elegível pelo poll.
+const lease = time.Minute
+
+// saveOutbox grava a entrega como pendente, já reservada para a fila em memória.
+func saveOutbox(job delivery) (*models.WebhookOutbox, error) {
+	row := &models.WebhookOutbox{
+		ID:          uuid.NewString(),
+		EventID:     job.eventID,
+		InstanceID:  job.instance,
+		Url:         job.url,
+		Global:      job.global,
+		Type:        job.kind,
+		Payload:     string(job.body),
+		Status:      models.WebhookPending,
+		NextAttempt: time.Now().Add(lease),
+	}
+
+	return row, database.Instance().Create(row).Error
+}
+
+// claimPending reserva até limit entregas pendentes cujo horário já chegou.
+// Cada linha é reservada com um UPDATE condicional: se outro processo a
+// reservou entre a leitura e o UPDATE, next_attempt já está no futuro, nenhuma
+// linha é afetada e a entrega fica com quem a reservou primeiro.
+func claimPending(limit int) ([]delivery, error) {
+	db := database.Instance()
+	now := time.Now()
+
+	var rows []models.WebhookOutbox
+	err := db.Where("status = ? AND next_attempt <= ?", models.WebhookPending, now).
+		Order("next_attempt").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	jobs := make([]delivery, 0, len(rows))
+	for _, row := range rows {
+		res := db.Model(&models.WebhookOutbox{}).
+			Where("id = ? AND status = ? AND next_attempt <= ?", row.ID, models.WebhookPending, now).
+			Update("next_attempt", now.Add(lease))
+		if res.Error != nil {
+			return jobs, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		jobs = append(jobs, delivery{
+			id:       row.ID,
+			eventID:  row.EventID,
+			url:      row.Url,
+			global:   row.Global,
+			kind:     row.Type,
+			instance: row.InstanceID,
+			body:     []byte(row.Payload),
+			attempts: row.Attempts,
+		})
+	}
+
+	return jobs, nil
+}
+
+// sweepDelivered apaga as entregas concluídas antes de before. Retorna
+// quantas linhas foram apagadas.
+func sweepDelivered(before time.Time) (int64, error) {
+	res := database.Instance().
+		Where("status = ? AND delivered_at < ?", models.WebhookDelivered, before).
+		Delete(&models.WebhookOutbox{})
+	return res.RowsAffected, res.Error
+}
+
+func markDelivered(id string) {
+	now := time.Now()
+	update(id, map[string]any{
+		"status":       models.WebhookDelivered,
+		"delivered_at": &now,
+		"last_error":   "",
+	})
+}
+
+func markRetry(id string, attempts int, err error, next time.Time) {
+	update(id, map[string]any{
+		"attempts":     attempts,
+		"last_error":   err.Error(),
+		"next_attempt": next,
+	})
+}
+
+func markFailed(id string, attempts int, err error) {
+	update(id, map[string]any{
+		"status":     models.WebhookFailed,
+		"attempts":   attempts,
+		"last_error": err.Error(),
+	})
+}
+
+func update(id string, fields map[string]any) {
+	err := database.Instance().Model(&models.WebhookOutbox{}).Where("id = ?", id).Updates(fields).Error
+	if err != nil {
+		log.Errorf("Erro ao atualizar outbox %s: %v", id, err)
+	}
+}
+
+// Failed lista os eventos da instância que esgotaram as tentativas, do mais recente ao mais antigo.
+func Failed(instance string, limit, offset int) ([]models.WebhookOutbox, int64, error) {
+	db := database.Instance().Model(&models.WebhookOutbox{}).
+		Where("instance_id = ? AND status = ?", instance, models.WebhookFailed).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []models.WebhookOutbox
+	err := db.Order("created_at DESC").Limit(limit).Offset(offset).Find(&rows).Error
+
+	return rows, total, err
+}
+
+// Replay devolve eventos da fila de falhas para o outbox. Sem ids, reenvia
+// todas as falhas da instância. Retorna quantos eventos foram reagendados.
+func Replay(instance string, ids []string) (int64, error) {
+	db := database.Instance().Model(&models.WebhookOutbox{}).
+		Where("instance_id = ? AND status = ?", instance, models.WebhookFailed)
+
+	if len(ids) > 0 {
+		db = db.Where("id IN ?", ids)
+	}
+
+	res := db.Updates(map[string]any{
+		"status":       models.WebhookPending,
+		"attempts":     0,
+		"next_attempt": time.Now(),
+	})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/database"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+)
+
+// openTestDatabase abre um banco sqlite migrado em um arquivo temporário: com
+// ":memory:" há uma única conexão e os pollers nunca concorreriam.
+func openTestDatabase(t *testing.T) {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "zaapi.db")
+	if err := database.Open(database.Config{Driver: "sqlite", DSN: dsn}); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+}
+
+// TestClaimPendingClaimsOnce garante que pollers concorrentes não reservam a
+// mesma entrega.
+func TestClaimPendingClaimsOnce(t *testing.T) {
+	openTestDatabase(t)
+
+	const total = 40
+	for n := range total {
+		row := &dbmodels.WebhookOutbox{
+			ID:          fmt.Sprintf("evt-%02d", n),
+			Status:      dbmodels.WebhookPending,
+			NextAttempt: time.Now().Add(-time.Second),
+		}
+		if err := database.Instance().Create(row).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		claimed = map[string]int{}
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobs, err := claimPending(total)
+			if err != nil {
+				t.Errorf("claimPending: %v", err)
+				return
+			}
+			mu.Lock()
+			for _, job := range jobs {
+				claimed[job.id]++
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != total {
+		t.Fatalf("%d entregas reservadas, esperado %d", len(claimed), total)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Fatalf("entrega %s reservada %d vezes", id, n)
+		}
+	}
+
+	if jobs, err := claimPending(total); err != nil || len(jobs) != 0 {
+		t.Fatalf("claimPending após reservar tudo = %d entregas (%v), esperado 0", len(jobs), err)
+	}
+}
+
+func TestSweepDelivered(t *testing.T) {
+	openTestDatabase(t)
+
+	old := time.Now().AddDate(0, 0, -10)
+	recent := time.Now()
+	for _, row := range []*dbmodels.WebhookOutbox{
+		{ID: "antiga", Status: dbmodels.WebhookDelivered, DeliveredAt: &old},
+		{ID: "recente", Status: dbmodels.WebhookDelivered, DeliveredAt: &recent},
+		{ID: "falha", Status: dbmodels.WebhookFailed},
+		{ID: "pendente", Status: dbmodels.WebhookPending},
+	} {
+		if err := database.Instance().Create(row).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	n, err := sweepDelivered(time.Now().AddDate(0, 0, -defaultRetention))
+	if err != nil || n != 1 {
+		t.Fatalf("sweepDelivered = %d (%v), esperado 1", n, err)
+	}
+
+	var left []string
+	database.Instance().Model(&dbmodels.WebhookOutbox{}).Order("id").Pluck("id", &left)
+	if fmt.Sprint(left) != "[falha pendente recente]" {
+		t.Fatalf("restaram %v", left)
+	}
+}
@@ -0,0 +1,385 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gedsonn/zaapi/internal/config"
+	"github.com/gedsonn/zaapi/internal/database"
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gedsonn/zaapi/internal/models"
+	"github.com/gedsonn/zaapi/pkg/signature"
+)
+
+const (
+	workers   = 4
+	queueSize = 1024
+
+	// Intervalo inicial e máximo entre as tentativas de entrega.
+	baseBackoff = time.Second
+	maxBackoff  = 30 * time.Second
+
+	// pollInterval é o intervalo de leitura do outbox em busca de novas tentativas.
+	pollInterval = 5 * time.Second
+
+	// sweepInterval é o intervalo entre as limpezas das entregas concluídas,
+	// que ficam no outbox por webhook.retention dias (defaultRetention se zero).
+	sweepInterval    = time.Hour
+	defaultRetention = 7
+)
+
+var ErrClosed = errors.New("dispatcher de webhook encerrado")
+
+// delivery é um evento aguardando entrega para uma URL. Quando o banco está
+// disponível, id aponta para a linha do outbox.
+type delivery struct {
+	id       string
+	eventID  string
+	url      string
+	kind     string
+	instance string
+	global   bool
+	body     []byte
+	attempts int
+}
+
+// Dispatcher entrega os eventos das instâncias para o webhook global e para o
+// webhook de cada instância.
+type Dispatcher struct {
+	cfg     config.WebhookConfig
+	secret  string
+	manager *maneger.Manager
+	client  *http.Client
+
+	queue  chan delivery
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New cria o dispatcher e inicia os workers de entrega. Com banco de dados, os
+// eventos passam pelo outbox e pendências de execuções anteriores são retomadas.
+// O manager é usado para buscar o secret de cada instância na hora da entrega.
+func New(cfg *config.Configuration, m *maneger.Manager) *Dispatcher {
+	timeout := time.Duration(cfg.Webhook.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	d := &Dispatcher{
+		cfg:     cfg.Webhook,
+		secret:  cfg.Secret,
+		manager: m,
+		client:  &http.Client{Timeout: timeout},
+		queue:   make(chan delivery, queueSize),
+		stop:    make(chan struct{}),
+	}
+
+	for n := 0; n < workers; n++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+
+	if database.Available() {
+		d.wg.Add(1)
+		go d.poll()
+	}
+
+	return d
+}
+
+// Handle é o maneger.EventHandler do dispatcher. O webhook global recebe todos
+// os eventos; o da instância só os habilitados em sua máscara.
+func (d *Dispatcher) Handle(i *maneger.Instancia, evt models.Event) {
+	if !d.cfg.Enabled {
+		return
+	}
+
+	if d.cfg.Global != "" {
+		d.enqueue(d.cfg.Global, true, evt)
+	}
+
+	url, mask := i.WebhookTarget()
+	if url == "" {
+		url = d.cfg.Local
+	}
+
+	if url != "" && url != d.cfg.Global && mask.Has(evt.Flag()) {
+		d.enqueue(url, false, evt)
+	}
+}
+
+// enqueue agenda a entrega sem bloquear o processamento de eventos.
+func (d *Dispatcher) enqueue(url string, global bool, evt models.Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return
+	}
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		log.Errorf("Erro ao serializar evento %s: %v", evt.Type, err)
+		return
+	}
+
+	job := delivery{
+		eventID:  evt.ID,
+		url:      url,
+		kind:     string(evt.Type),
+		instance: evt.Instance,
+		global:   global,
+		body:     body,
+	}
+
+	if database.Available() {
+		row, err := saveOutbox(job)
+		if err != nil {
+			log.Errorf("Erro ao gravar evento %s no outbox: %v", evt.Type, err)
+		} else {
+			job.id = row.ID
+		}
+	}
+
+	select {
+	case d.queue <- job:
+	default:
+		// Com outbox o evento continua pendente e é retomado pelo poll.
+		if job.id == "" {
+			log.Warnf("Fila de webhook cheia, descartando evento %s da instância %s", evt.Type, evt.Instance)
+		}
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for job := range d.queue {
+		if job.id != "" {
+			d.deliverOutbox(job)
+			continue
+		}
+
+		if err := d.deliver(context.Background(), job); err != nil {
+			log.Errorf("Erro ao entregar evento %s para %s: %v", job.kind, job.url, err)
+		}
+	}
+}
+
+// deliverOutbox faz uma tentativa e registra o resultado no outbox. Falhas são
+// reagendadas com backoff pelo poll até esgotar Retries.
+func (d *Dispatcher) deliverOutbox(job delivery) {
+	retry, err := d.post(context.Background(), job)
+	if err == nil {
+		markDelivered(job.id)
+		return
+	}
+
+	attempts := job.attempts + 1
+	if !retry || attempts > d.cfg.Retries {
+		markFailed(job.id, attempts, err)
+		log.Errorf("Evento %s para %s movido para a fila de falhas: %v", job.kind, job.url, err)
+		return
+	}
+
+	markRetry(job.id, attempts, err, time.Now().Add(backoff(attempts)))
+}
+
+// poll lê periodicamente o outbox e reenfileira as entregas cujo horário chegou.
+func (d *Dispatcher) poll() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var swept time.Time
+	for {
+		if time.Since(swept) >= sweepInterval {
+			d.sweep()
+			swept = time.Now()
+		}
+
+		jobs, err := claimPending(queueSize / 2)
+		if err != nil {
+			log.Errorf("Erro ao ler outbox de webhooks: %v", err)
+		}
+
+		for _, job := range jobs {
+			if !d.push(job) {
+				return
+			}
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep apaga do outbox as entregas concluídas há mais de webhook.retention dias.
+func (d *Dispatcher) sweep() {
+	days := d.cfg.Retention
+	if days <= 0 {
+		days = defaultRetention
+	}
+
+	n, err := sweepDelivered(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Errorf("Erro ao limpar outbox de webhooks: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Infof("%d eventos entregues removidos do outbox de webhooks", n)
+	}
+}
+
+// push coloca a entrega na fila, desistindo se o dispatcher for encerrado.
+func (d *Dispatcher) push(job delivery) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return false
+	}
+
+	select {
+	case d.queue <- job:
+	default:
+		// Fila cheia: o evento continua pendente para o próximo ciclo.
+	}
+	return true
+}
+
+// Deliver envia o evento para a URL, assinado com o secret global, tentando
+// novamente com backoff exponencial até Retries vezes em caso de falha de rede,
+// 429 ou 5xx.
+func (d *Dispatcher) Deliver(ctx context.Context, url string, evt models.Event) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	return d.deliver(ctx, delivery{
+		eventID:  evt.ID,
+		url:      url,
+		kind:     string(evt.Type),
+		instance: evt.Instance,
+		global:   true,
+		body:     body,
+	})
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, job delivery) error {
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(ctx, job)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt > d.cfg.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+// post faz uma tentativa de entrega. retry indica se a falha é temporária.
+func (d *Dispatcher) post(ctx context.Context, job delivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.url, bytes.NewReader(job.body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zaapi")
+	req.Header.Set("X-Zaapi-Event", job.kind)
+	req.Header.Set("X-Zaapi-Instance", job.instance)
+	req.Header.Set(signature.HeaderEventID, job.eventID)
+
+	if secret := d.secretFor(job); secret != "" {
+		ts := time.Now().Unix()
+		req.Header.Set(signature.HeaderTimestamp, strconv.FormatInt(ts, 10))
+		req.Header.Set(signature.HeaderSignature, signature.Sign([]byte(secret), ts, job.body))
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry = res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	return retry, fmt.Errorf("webhook respondeu com status %d", res.StatusCode)
+}
+
+// secretFor retorna o secret usado para assinar a entrega: o da instância,
+// se houver, para o webhook da instância; o global nos demais casos.
+func (d *Dispatcher) secretFor(job delivery) string {
+	if !job.global && d.manager != nil {
+		if i, ok := d.manager.Get(job.instance); ok {
+			if secret := i.Secret(); secret != "" {
+				return secret
+			}
+		}
+	}
+	return d.secret
+}
+
+// backoff retorna a espera antes da próxima tentativa: 1s, 2s, 4s... até maxBackoff.
+func backoff(attempt int) time.Duration {
+	wait := baseBackoff << (attempt - 1)
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+// Close para de aceitar eventos e aguarda a fila ser entregue ou o contexto
+// expirar. Eventos que ficarem no outbox são retomados na próxima execução.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return ErrClosed
+	}
+	d.closed = true
+	close(d.stop)
+	close(d.queue)
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,240 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gedsonn/zaapi/internal/config"
+	dbmodels "github.com/gedsonn/zaapi/internal/database/models"
+	"github.com/gedsonn/zaapi/internal/maneger"
+	"github.com/gedsonn/zaapi/internal/models"
+	"github.com/gedsonn/zaapi/pkg/signature"
+)
+
+const testSecret = "segredo"
+
+// receiver é um webhook de teste que guarda os eventos recebidos por caminho.
+type receiver struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	events map[string][]models.Event
+	status func(path string, hit int) int
+	hits   atomic.Int32
+}
+
+func newReceiver(t *testing.T) *receiver {
+	t.Helper()
+
+	r := &receiver{events: map[string][]models.Event{}}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hit := int(r.hits.Add(1))
+
+		body, err := signature.VerifyRequest(req, []byte(testSecret), time.Minute)
+		if err != nil {
+			t.Errorf("assinatura inválida: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var evt models.Event
+		if err := json.Unmarshal(body, &evt); err != nil {
+			t.Errorf("corpo inválido: %v", err)
+		}
+		if got := req.Header.Get(signature.HeaderEventID); got != evt.ID {
+			t.Errorf("%s = %q, esperado %q", signature.HeaderEventID, got, evt.ID)
+		}
+
+		if r.status != nil {
+			if code := r.status(req.URL.Path, hit); code != http.StatusOK {
+				w.WriteHeader(code)
+				return
+			}
+		}
+
+		r.mu.Lock()
+		r.events[req.URL.Path] = append(r.events[req.URL.Path], evt)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *receiver) received(path string) []models.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events[path]
+}
+
+func newDispatcher(t *testing.T, cfg config.WebhookConfig) *Dispatcher {
+	t.Helper()
+
+	cfg.Enabled = true
+	d := New(&config.Configuration{Secret: testSecret, Webhook: cfg}, nil)
+	t.Cleanup(func() { d.Close(context.Background()) })
+	return d
+}
+
+func event(id string, kind models.EventType) models.Event {
+	return models.Event{ID: id, Type: kind, Instance: "teste", Timestamp: time.Now()}
+}
+
+func TestDeliverSignsEvent(t *testing.T) {
+	r := newReceiver(t)
+	d := newDispatcher(t, config.WebhookConfig{})
+
+	if err := d.Deliver(context.Background(), r.URL+"/hook", event("evt-1", models.EventQR)); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+
+	got := r.received("/hook")
+	if len(got) != 1 || got[0].ID != "evt-1" || got[0].Type != models.EventQR {
+		t.Fatalf("eventos recebidos = %+v", got)
+	}
+}
+
+func TestDeliverRetriesTemporaryFailures(t *testing.T) {
+	r := newReceiver(t)
+	r.status = func(_ string, hit int) int {
+		if hit == 1 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	}
+	d := newDispatcher(t, config.WebhookConfig{Retries: 3})
+
+	start := time.Now()
+	if err := d.Deliver(context.Background(), r.URL+"/hook", event("evt-1", models.EventQR)); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+
+	if hits := r.hits.Load(); hits != 2 {
+		t.Fatalf("tentativas = %d, esperado 2", hits)
+	}
+	if elapsed := time.Since(start); elapsed < baseBackoff {
+		t.Fatalf("nova tentativa após %s, esperado ao menos %s", elapsed, baseBackoff)
+	}
+}
+
+func TestDeliverGivesUpAfterRetries(t *testing.T) {
+	r := newReceiver(t)
+	r.status = func(string, int) int { return http.StatusInternalServerError }
+	d := newDispatcher(t, config.WebhookConfig{Retries: 1})
+
+	if err := d.Deliver(context.Background(), r.URL+"/hook", event("evt-1", models.EventQR)); err == nil {
+		t.Fatal("Deliver não retornou erro")
+	}
+	if hits := r.hits.Load(); hits != 2 {
+		t.Fatalf("tentativas = %d, esperado 2", hits)
+	}
+}
+
+func TestDeliverDoesNotRetryClientErrors(t *testing.T) {
+	r := newReceiver(t)
+	r.status = func(string, int) int { return http.StatusBadRequest }
+	d := newDispatcher(t, config.WebhookConfig{Retries: 3})
+
+	if err := d.Deliver(context.Background(), r.URL+"/hook", event("evt-1", models.EventQR)); err == nil {
+		t.Fatal("Deliver não retornou erro")
+	}
+	if hits := r.hits.Load(); hits != 1 {
+		t.Fatalf("tentativas = %d, esperado 1", hits)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, esperado %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestHandleFiltersByEventMask(t *testing.T) {
+	r := newReceiver(t)
+	d := New(&config.Configuration{
+		Secret:  testSecret,
+		Webhook: config.WebhookConfig{Enabled: true, Global: r.URL + "/global"},
+	}, nil)
+
+	instance := &maneger.Instancia{
+		Id:            "teste",
+		Webhook:       r.URL + "/instance",
+		WebhookEvents: dbmodels.EventQR | dbmodels.EventLoggedIn,
+	}
+
+	d.Handle(instance, event("evt-1", models.EventQR))
+	d.Handle(instance, event("evt-2", models.EventPresence))
+	d.Handle(instance, event("evt-3", models.EventLoggedIn))
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got := r.received("/global"); len(got) != 3 {
+		t.Errorf("webhook global recebeu %d eventos, esperado 3", len(got))
+	}
+
+	got := r.received("/instance")
+	if len(got) != 2 {
+		t.Fatalf("webhook da instância recebeu %d eventos, esperado 2", len(got))
+	}
+	for _, evt := range got {
+		if evt.Type == models.EventPresence {
+			t.Errorf("evento %s fora da máscara foi entregue", evt.Type)
+		}
+	}
+}
+
+func TestHandleEmptyMaskReceivesAll(t *testing.T) {
+	r := newReceiver(t)
+	d := New(&config.Configuration{Secret: testSecret, Webhook: config.WebhookConfig{Enabled: true}}, nil)
+
+	instance := &maneger.Instancia{Id: "teste", Webhook: r.URL + "/instance"}
+	d.Handle(instance, event("evt-1", models.EventQR))
+	d.Handle(instance, event("evt-2", models.EventPresence))
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got := r.received("/instance"); len(got) != 2 {
+		t.Fatalf("webhook da instância recebeu %d eventos, esperado 2", len(got))
+	}
+}
+
+func TestHandleDisabled(t *testing.T) {
+	r := newReceiver(t)
+	d := New(&config.Configuration{
+		Secret:  testSecret,
+		Webhook: config.WebhookConfig{Enabled: false, Global: r.URL + "/global"},
+	}, nil)
+
+	d.Handle(&maneger.Instancia{Id: "teste"}, event("evt-1", models.EventQR))
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if hits := r.hits.Load(); hits != 0 {
+		t.Fatalf("webhook desabilitado recebeu %d eventos", hits)
+	}
+}
@@ -0,0 +1,100 @@
+// Package signature assina e verifica os webhooks enviados pelo Zaapi.
+//
+// Cada requisição traz os cabeçalhos X-Zaapi-Timestamp (unix, em segundos),
+// X-Zaapi-Signature ("sha256=<hex>") e X-Zaapi-Event-Id. A assinatura é o
+// HMAC-SHA256 de "<timestamp>.<corpo>" com o secret configurado no Zaapi.
+//
+// No receptor:
+//
+//	body, err := signature.VerifyRequest(r, []byte(secret), 5*time.Minute)
+//	if err != nil {
+//		http.Error(w, err.Error(), http.StatusUnauthorized)
+//		return
+//	}
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Zaapi-Signature"
+	HeaderTimestamp = "X-Zaapi-Timestamp"
+	HeaderEventID   = "X-Zaapi-Event-Id"
+
+	prefix = "sha256="
+)
+
+var (
+	ErrMissingHeaders   = errors.New("signature: cabeçalhos de assinatura ausentes")
+	ErrInvalidTimestamp = errors.New("signature: timestamp inválido")
+	ErrExpired          = errors.New("signature: timestamp fora da tolerância")
+	ErrMismatch         = errors.New("signature: assinatura não confere")
+)
+
+// Sign retorna a assinatura ("sha256=<hex>") do corpo no instante informado.
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify confere a assinatura e se o timestamp está dentro da tolerância,
+// protegendo contra reenvio de requisições antigas. tolerance <= 0 não limita.
+func Verify(secret []byte, timestamp, sig string, body []byte, tolerance time.Duration) error {
+	if timestamp == "" || sig == "" {
+		return ErrMissingHeaders
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	if tolerance > 0 {
+		diff := time.Since(time.Unix(ts, 0))
+		if diff < -tolerance || diff > tolerance {
+			return ErrExpired
+		}
+	}
+
+	if !strings.HasPrefix(sig, prefix) {
+		return ErrMismatch
+	}
+
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+// VerifyRequest lê o corpo da requisição, verifica a assinatura e devolve o
+// corpo. r.Body é restaurado para poder ser lido novamente.
+func VerifyRequest(r *http.Request, secret []byte, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = Verify(secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, tolerance)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,100 @@
+package signature
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	secret = []byte("segredo")
+	body   = []byte(`{"id":"evt-1","type":"message"}`)
+)
+
+func TestSignIsDeterministic(t *testing.T) {
+	a := Sign(secret, 1700000000, body)
+	b := Sign(secret, 1700000000, body)
+
+	if a != b {
+		t.Fatalf("assinaturas diferentes para a mesma entrada: %s, %s", a, b)
+	}
+	if !strings.HasPrefix(a, "sha256=") || len(a) != len("sha256=")+64 {
+		t.Fatalf("formato inesperado: %s", a)
+	}
+	if a == Sign(secret, 1700000001, body) {
+		t.Fatal("timestamp não faz parte da assinatura")
+	}
+	if a == Sign([]byte("outro"), 1700000000, body) {
+		t.Fatal("secret não faz parte da assinatura")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now().Unix()
+	ts := strconv.FormatInt(now, 10)
+	old := strconv.FormatInt(now-600, 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		sig       string
+		body      []byte
+		tolerance time.Duration
+		want      error
+	}{
+		{"válida", ts, Sign(secret, now, body), body, time.Minute, nil},
+		{"sem cabeçalhos", "", "", body, time.Minute, ErrMissingHeaders},
+		{"timestamp inválido", "abc", Sign(secret, now, body), body, time.Minute, ErrInvalidTimestamp},
+		{"expirada", old, Sign(secret, now-600, body), body, time.Minute, ErrExpired},
+		{"expirada sem tolerância", old, Sign(secret, now-600, body), body, 0, nil},
+		{"corpo alterado", ts, Sign(secret, now, body), []byte(`{}`), time.Minute, ErrMismatch},
+		{"secret errado", ts, Sign([]byte("outro"), now, body), body, time.Minute, ErrMismatch},
+		{"sem prefixo", ts, strings.TrimPrefix(Sign(secret, now, body), "sha256="), body, time.Minute, ErrMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(secret, tt.timestamp, tt.sig, tt.body, tt.tolerance)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, esperado %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRequestRestoresBody(t *testing.T) {
+	now := time.Now().Unix()
+
+	r := httptest.NewRequest("POST", "/webhook", strings.NewReader(string(body)))
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+	r.Header.Set(HeaderSignature, Sign(secret, now, body))
+
+	got, err := VerifyRequest(r, secret, time.Minute)
+	if err != nil {
+		t.Fatalf("VerifyRequest: %v", err)
+	}
+	if string(got) != string(body) {
+		t.Fatalf("corpo = %s, esperado %s", got, body)
+	}
+
+	again, err := io.ReadAll(r.Body)
+	if err != nil || string(again) != string(body) {
+		t.Fatalf("corpo não foi restaurado: %q, %v", again, err)
+	}
+}
+
+func TestVerifyRequestRejectsTampering(t *testing.T) {
+	now := time.Now().Unix()
+
+	r := httptest.NewRequest("POST", "/webhook", strings.NewReader(`{"id":"evt-2"}`))
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+	r.Header.Set(HeaderSignature, Sign(secret, now, body))
+
+	if _, err := VerifyRequest(r, secret, time.Minute); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("VerifyRequest = %v, esperado %v", err, ErrMismatch)
+	}
+}